@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/h3poteto/kube-job/pkg/job"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+	v1 "k8s.io/api/batch/v1"
+)
+
+// diffContext is the number of unchanged lines around the changes in the diff.
+const diffContext = 2
+
+// confirm asks the user to type the namespace before running the job in a protected context or namespace.
+func (r *runJob) confirm(config job.KubeconfigOptions, j *job.Job) error {
+	if r.yes {
+		return nil
+	}
+	kubeContext, err := job.ResolveContext(config)
+	if err != nil {
+		return err
+	}
+	return confirmNamespace(kubeContext, j.CurrentJob.Namespace, func(w io.Writer) error {
+		built, err := j.BuildJob()
+		if err != nil {
+			return err
+		}
+		return printSummary(w, kubeContext, j.CurrentJob, built)
+	})
+}
+
+// confirmNamespace asks the user to type the namespace if the context or the namespace is protected,
+// which are protected-contexts and protected-namespaces in .kube-job.yaml.
+// summary prints what is run before asking.
+func confirmNamespace(kubeContext job.KubeconfigContext, namespace string, summary func(io.Writer) error) error {
+	if !matchAny(viper.GetStringSlice("protected-contexts"), kubeContext.Name) && !matchAny(viper.GetStringSlice("protected-namespaces"), namespace) {
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("namespace %s in context %s is protected, please set --yes to run without confirmation", namespace, kubeContext.Name)
+	}
+	if err := summary(os.Stderr); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "\nNamespace %s is protected. Type the namespace to run the job: ", namespace)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if strings.TrimSpace(answer) != namespace {
+		return errors.New("confirmation does not match the namespace, the job is not run")
+	}
+	return nil
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// printSummary prints where and what the job runs, and the diff from the template.
+func printSummary(w io.Writer, kubeContext job.KubeconfigContext, template, built *v1.Job) error {
+	fmt.Fprintf(w, "Context:   %s\n", kubeContext.Name)
+	fmt.Fprintf(w, "Cluster:   %s (%s)\n", kubeContext.Cluster, kubeContext.Server)
+	fmt.Fprintf(w, "Namespace: %s\n", built.Namespace)
+	fmt.Fprintf(w, "Job:       %s\n", built.Name)
+	for _, container := range built.Spec.Template.Spec.Containers {
+		if container.Name != built.Labels[job.ContainerLabel] {
+			continue
+		}
+		fmt.Fprintf(w, "Image:     %s\n", container.Image)
+		fmt.Fprintf(w, "Args:      %s\n", strings.Join(container.Args, " "))
+	}
+
+	before, err := yaml.Marshal(template)
+	if err != nil {
+		return err
+	}
+	after, err := yaml.Marshal(built)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nDiff from the template:")
+	for _, line := range diffLines(strings.Split(string(before), "\n"), strings.Split(string(after), "\n")) {
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// diffLines returns the changed lines with "-" and "+", and unchanged lines around them.
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+
+	changed := make([]bool, len(lines))
+	for k, line := range lines {
+		if !strings.HasPrefix(line, "  ") {
+			for c := max(0, k-diffContext); c <= min(len(lines)-1, k+diffContext); c++ {
+				changed[c] = true
+			}
+		}
+	}
+	result := []string{}
+	skipped := false
+	for k, line := range lines {
+		if !changed[k] {
+			skipped = true
+			continue
+		}
+		if skipped && len(result) > 0 {
+			result = append(result, "  ...")
+		}
+		skipped = false
+		result = append(result, line)
+	}
+	return result
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/h3poteto/kube-job/pkg/job"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+func TestConfirmNamespace(t *testing.T) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		t.Skip("stdin is a terminal")
+	}
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("protected-namespaces", []string{"prod-*"})
+	kubeContext := job.KubeconfigContext{Name: "staging"}
+	summary := func(io.Writer) error { return nil }
+
+	if err := confirmNamespace(kubeContext, "web", summary); err != nil {
+		t.Errorf("web should not be protected: %v", err)
+	}
+	if err := confirmNamespace(kubeContext, "prod-db", summary); err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("prod-db should be protected: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/h3poteto/kube-job/pkg/job"
+)
+
+// logEvent writes lifecycle events of the jobs to the log.
+func logEvent(e job.Event) {
+	switch e.Type {
+	case job.EventJobCreated:
+		slog.Info("Starting job", "job", e.Job)
+	case job.EventPodScheduled:
+		slog.Info("Pod is scheduled", "job", e.Job, "pod", e.Pod, "node", e.Reason)
+	case job.EventContainerStarted:
+		slog.Info("Container is started", "job", e.Job, "pod", e.Pod, "container", e.Container)
+	case job.EventContainerTerminated:
+		if e.ExitCode == 0 {
+			slog.Info("Container is terminated", "job", e.Job, "pod", e.Pod, "container", e.Container, "exitCode", e.ExitCode)
+		} else {
+			slog.Warn("Container is terminated", "job", e.Job, "pod", e.Pod, "container", e.Container, "exitCode", e.ExitCode, "reason", e.Reason)
+		}
+	case job.EventRetry:
+		slog.Warn("Job is failed, retrying", "job", e.Job, "attempt", e.Attempt, "reason", e.Reason, "error", e.Err, "backoff", e.Backoff)
+	case job.EventJobFinished:
+		if e.Err == nil {
+			slog.Info("Job is succeeded", "job", e.Job)
+		} else {
+			slog.Info("Job is failed", "job", e.Job, "error", e.Err)
+		}
+	case job.EventCleanupDone:
+		if e.Err == nil {
+			slog.Info("Removed the job", "job", e.Job)
+		} else {
+			slog.Warn("Failed to remove the job", "job", e.Job, "error", e.Err)
+		}
+	}
+}
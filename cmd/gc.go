@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/h3poteto/kube-job/pkg/job"
+	"github.com/spf13/cobra"
+)
+
+type gc struct {
+	namespace     string
+	allNamespaces bool
+	olderThan     time.Duration
+	status        string
+	keepLast      int
+	dryRun        bool
+}
+
+func gcCmd() *cobra.Command {
+	g := &gc{}
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove finished jobs which are created by kube-job",
+		Run:   g.run,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&g.namespace, "namespace", "", "Namespace of the jobs (If you don't set it, the namespace of the context is used)")
+	flags.BoolVarP(&g.allNamespaces, "all-namespaces", "A", false, "Remove jobs in all namespaces")
+	flags.DurationVar(&g.olderThan, "older-than", 24*time.Hour, "Remove jobs which are created before this duration, like 24h")
+	flags.StringVar(&g.status, "status", "", "Status of the jobs. You can specify 'Succeeded' or 'Failed'. If you don't set it, both are removed.")
+	flags.IntVar(&g.keepLast, "keep-last", 0, "Number of the newest finished jobs which are kept for each template")
+	flags.BoolVar(&g.dryRun, "dry-run", false, "Only print jobs which will be removed")
+
+	return cmd
+}
+
+func (g *gc) run(cmd *cobra.Command, args []string) {
+	config := generalConfig()
+
+	slog.Info("Using config file", "path", config.Path)
+	client, namespace, err := job.NewClientWithKubeconfig(config)
+	if err != nil {
+		fatal(err)
+	}
+	if len(g.namespace) > 0 {
+		namespace = g.namespace
+	}
+	options := job.GCOptions{
+		Namespace: namespace,
+		OlderThan: g.olderThan,
+		Status:    g.status,
+		KeepLast:  g.keepLast,
+		DryRun:    g.dryRun,
+		Logger:    slog.Default(),
+	}
+	if g.allNamespaces {
+		options.Namespace = ""
+	}
+	removed, err := job.CollectGarbage(cmd.Context(), client, options)
+	for _, s := range removed {
+		if g.dryRun {
+			fmt.Printf("job.batch/%s removed in %s (dry run)\n", s.Name, s.Namespace)
+		} else {
+			fmt.Printf("job.batch/%s removed in %s\n", s.Name, s.Namespace)
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
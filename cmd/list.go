@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/h3poteto/kube-job/pkg/job"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+type listJobs struct {
+	namespace     string
+	allNamespaces bool
+	template      string
+	status        string
+	output        string
+}
+
+func listJobsCmd() *cobra.Command {
+	l := &listJobs{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List jobs which are created by kube-job",
+		Run:   l.run,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&l.namespace, "namespace", "", "Namespace of the jobs (If you don't set it, the namespace of the context is used)")
+	flags.BoolVarP(&l.allNamespaces, "all-namespaces", "A", false, "List jobs in all namespaces")
+	flags.StringVar(&l.template, "template", "", "Name of the job template")
+	flags.StringVar(&l.status, "status", "", "Status of the jobs. You can specify 'Running', 'Succeeded' or 'Failed'.")
+	flags.StringVarP(&l.output, "output", "o", "", "Output format. You can specify 'wide', 'json' or 'yaml'.")
+
+	return cmd
+}
+
+func (l *listJobs) run(cmd *cobra.Command, args []string) {
+	config := generalConfig()
+	if l.output != "" && l.output != "wide" && l.output != "json" && l.output != "yaml" {
+		fatal(errors.New("please set 'wide', 'json' or 'yaml' as --output"))
+	}
+
+	slog.Info("Using config file", "path", config.Path)
+	client, namespace, err := job.NewClientWithKubeconfig(config)
+	if err != nil {
+		fatal(err)
+	}
+	if len(l.namespace) > 0 {
+		namespace = l.namespace
+	}
+	options := job.ListOptions{
+		Namespace: namespace,
+		Template:  l.template,
+		Status:    l.status,
+	}
+	if l.allNamespaces {
+		options.Namespace = ""
+	}
+	summaries, err := job.ListJobs(cmd.Context(), client, options)
+	if err != nil {
+		fatal(err)
+	}
+	if err := printJobSummaries(os.Stdout, summaries, l.output); err != nil {
+		fatal(err)
+	}
+}
+
+func printJobSummaries(out io.Writer, summaries []job.JobSummary, output string) error {
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summaries)
+	case "yaml":
+		data, err := yaml.Marshal(summaries)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	if output == "wide" {
+		fmt.Fprintln(w, "NAMESPACE\tNAME\tTEMPLATE\tSTATUS\tAGE\tDURATION\tEXIT CODE\tUSER\tRUN ID")
+	} else {
+		fmt.Fprintln(w, "NAMESPACE\tNAME\tTEMPLATE\tSTATUS\tAGE\tDURATION\tEXIT CODE")
+	}
+	for _, s := range summaries {
+		exitCode := "<none>"
+		if s.ExitCode != nil {
+			exitCode = strconv.Itoa(int(*s.ExitCode))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s", s.Namespace, s.Name, s.Template, s.Status, duration.HumanDuration(s.Age(now)), duration.HumanDuration(s.Duration(now)), exitCode)
+		if output == "wide" {
+			fmt.Fprintf(w, "\t%s\t%s", s.User, s.RunID)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+)
+
+// newLogger returns the logger which writes diagnostic messages of the CLI to stderr.
+func newLogger(format string, verbose bool) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: slog.LevelWarn}
+	if verbose {
+		options.Level = slog.LevelDebug
+	}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, options)), nil
+	}
+	return nil, errors.New("please set 'text' or 'json' as --log-format")
+}
+
+// fatal writes the error and exits.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
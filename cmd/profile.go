@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// loadProfile merges the profile selected with --profile into the config.
+// Values in the profile take precedence over the top level values in .kube-job.yaml.
+func loadProfile() error {
+	name := viper.GetString("profile")
+	if len(name) == 0 {
+		return nil
+	}
+	key := "profiles." + name
+	if !viper.IsSet(key) {
+		return fmt.Errorf("profile %s is not found", name)
+	}
+	return viper.MergeConfigMap(viper.GetStringMap(key))
+}
+
+// commandLineOnly are the flags which are not read from the config and the environment variables,
+// because they must be confirmed for each run.
+var commandLineOnly = map[string]bool{
+	"profile":         true,
+	"yes":             true,
+	"override-policy": true,
+}
+
+// applyConfig sets the flags which are not specified in the command line,
+// from KUBE_JOB_* environment variables, the profile and .kube-job.yaml.
+// A repeatable flag takes a list in the config, and a single value in the environment variable.
+func applyConfig(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || commandLineOnly[f.Name] || !viper.IsSet(f.Name) {
+			return
+		}
+		value := viper.Get(f.Name)
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			err = slice.Replace(configValues(value))
+		} else {
+			err = f.Value.Set(fmt.Sprint(value))
+		}
+		if err != nil {
+			err = fmt.Errorf("invalid value for %s in the config: %w", f.Name, err)
+		}
+	})
+	return err
+}
+
+// configValues converts the value in the config to values of a repeatable flag.
+func configValues(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	}
+	return []string{fmt.Sprint(value)}
+}
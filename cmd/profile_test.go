@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const testConfig = `
+namespace: default
+cleanup: succeeded
+timeout: 60
+yes: true
+override-policy: true
+profiles:
+  migrate:
+    namespace: web
+    timeout: 600
+    env:
+    - RAILS_ENV=production
+    - LOG_LEVEL=debug
+`
+
+// loadTestConfig reads the config like initConfig, and selects the profile.
+func loadTestConfig(t *testing.T, profile string) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	bindEnv()
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(strings.NewReader(testConfig)); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("profile", profile)
+	if err := loadProfile(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func applyTestConfig(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := runJobCmd()
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(cmd); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestLoadProfile(t *testing.T) {
+	loadTestConfig(t, "migrate")
+	cmd := applyTestConfig(t)
+	if namespace, _ := cmd.Flags().GetString("namespace"); namespace != "web" {
+		t.Errorf("profile should take precedence over the top level: %s", namespace)
+	}
+	if cleanup, _ := cmd.Flags().GetString("cleanup"); cleanup != "succeeded" {
+		t.Errorf("top level value should be used if the profile does not have it: %s", cleanup)
+	}
+	if env, _ := cmd.Flags().GetStringArray("env"); len(env) != 2 || env[0] != "RAILS_ENV=production" || env[1] != "LOG_LEVEL=debug" {
+		t.Errorf("list in the profile should be set to the repeatable flag: %v", env)
+	}
+
+	viper.Set("profile", "unknown")
+	if err := loadProfile(); err == nil {
+		t.Error("unknown profile should be error")
+	}
+}
+
+func TestApplyConfigPrecedence(t *testing.T) {
+	t.Setenv("KUBE_JOB_NAMESPACE", "batch")
+	t.Setenv("KUBE_JOB_TIMEOUT", "900")
+	loadTestConfig(t, "migrate")
+	cmd := applyTestConfig(t, "--timeout=30")
+	if namespace, _ := cmd.Flags().GetString("namespace"); namespace != "batch" {
+		t.Errorf("environment variable should take precedence over the profile: %s", namespace)
+	}
+	if timeout, _ := cmd.Flags().GetInt("timeout"); timeout != 30 {
+		t.Errorf("command line should take precedence over the environment variable: %d", timeout)
+	}
+}
+
+func TestApplyConfigSliceFromEnv(t *testing.T) {
+	t.Setenv("KUBE_JOB_ENV", "GREETING=hello, world")
+	t.Setenv("KUBE_JOB_FOR_EACH", "tenant=a,b")
+	loadTestConfig(t, "migrate")
+	cmd := applyTestConfig(t)
+	if env, _ := cmd.Flags().GetStringArray("env"); len(env) != 1 || env[0] != "GREETING=hello, world" {
+		t.Errorf("environment variable should be a single value of the repeatable flag: %v", env)
+	}
+	if forEach, _ := cmd.Flags().GetStringArray("for-each"); len(forEach) != 1 || forEach[0] != "tenant=a,b" {
+		t.Errorf("environment variable should not be split: %v", forEach)
+	}
+}
+
+func TestApplyConfigCommandLineOnly(t *testing.T) {
+	t.Setenv("KUBE_JOB_YES", "true")
+	loadTestConfig(t, "")
+	cmd := applyTestConfig(t)
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		t.Error("--yes should not be read from the config or the environment variables")
+	}
+	if overridePolicy, _ := cmd.Flags().GetBool("override-policy"); overridePolicy {
+		t.Error("--override-policy should not be read from the config")
+	}
+}
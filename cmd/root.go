@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/h3poteto/kube-job/pkg/job"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -12,22 +16,89 @@ var RootCmd = &cobra.Command{
 	Short:         "Run one off job on kubernetes",
 	SilenceErrors: true,
 	SilenceUsage:  true,
+
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadProfile(); err != nil {
+			return err
+		}
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		return setupLogger(cmd, args)
+	},
 }
 
 func init() {
-	cobra.OnInitialize()
-	RootCmd.PersistentFlags().StringP("config", "", "", "Kubernetes config file path (If you don't set it, use environment variables `KUBECONFIG`)")
-	RootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose mode")
-	_ = viper.BindPFlag("config", RootCmd.PersistentFlags().Lookup("config"))
-	_ = viper.BindPFlag("verbose", RootCmd.PersistentFlags().Lookup("verbose"))
+	job.Version = version
+	cobra.OnInitialize(initConfig)
+	flags := RootCmd.PersistentFlags()
+	flags.StringP("config", "", "", "Kubernetes config file path (If you don't set it, use environment variables `KUBECONFIG`)")
+	flags.String("context", "", "Name of the kubeconfig context to use")
+	flags.String("cluster", "", "Name of the kubeconfig cluster to use")
+	flags.String("user", "", "Name of the kubeconfig user to use")
+	flags.String("server", "", "Address of the Kubernetes API server")
+	flags.String("token", "", "Bearer token for authentication to the API server")
+	flags.String("certificate-authority", "", "Path to a cert file for the certificate authority")
+	flags.Bool("insecure-skip-tls-verify", false, "If true, the server's certificate will not be checked for validity")
+	flags.String("as", "", "Username to impersonate for the operation")
+	flags.StringArray("as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
+	flags.String("as-uid", "", "UID to impersonate for the operation")
+	flags.String("auth", job.AuthAuto, "How to authenticate, 'kubeconfig', 'in-cluster' or 'auto' (auto uses in-cluster config only if the kubeconfig does not exist)")
+	flags.BoolP("verbose", "v", false, "Enable verbose mode")
+	flags.String("log-format", "text", "Format of the diagnostic logs, 'text' or 'json'")
+	flags.String("profile", "", "Name of the profile in .kube-job.yaml which has default values of the flags")
+	for _, name := range []string{"profile", "config", "context", "cluster", "user", "server", "token", "certificate-authority", "insecure-skip-tls-verify", "as", "as-group", "as-uid", "auth", "verbose", "log-format"} {
+		_ = viper.BindPFlag(name, flags.Lookup(name))
+	}
 
 	RootCmd.AddCommand(
 		runJobCmd(),
+		workflowCmd(),
+		listJobsCmd(),
+		gcCmd(),
 		versionCmd(),
 	)
 }
 
-func generalConfig() (string, bool) {
+// initConfig reads .kube-job.yaml in the current directory or $XDG_CONFIG_HOME/kube-job,
+// which has default values of the flags. KUBE_JOB_* environment variables override them,
+// like KUBE_JOB_NAMESPACE for --namespace.
+func initConfig() {
+	bindEnv()
+	viper.SetConfigName(".kube-job")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath(".")
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if len(configHome) == 0 {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	viper.AddConfigPath(filepath.Join(configHome, "kube-job"))
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			fatal(err)
+		}
+	}
+}
+
+// bindEnv reads KUBE_JOB_* environment variables as the values of the flags.
+func bindEnv() {
+	viper.SetEnvPrefix("KUBE_JOB")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	viper.AutomaticEnv()
+}
+
+// setupLogger configures the default logger with --log-format and --verbose.
+func setupLogger(cmd *cobra.Command, args []string) error {
+	logger, err := newLogger(viper.GetString("log-format"), viper.GetBool("verbose"))
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// generalConfig returns the kubeconfig options from the flags.
+func generalConfig() job.KubeconfigOptions {
 	config := viper.GetString("config")
 	if len(config) == 0 {
 		config = os.Getenv("KUBECONFIG")
@@ -35,5 +106,18 @@ func generalConfig() (string, bool) {
 			config = "$HOME/.kube/config"
 		}
 	}
-	return config, viper.GetBool("verbose")
+	return job.KubeconfigOptions{
+		Path:                  config,
+		Context:               viper.GetString("context"),
+		Cluster:               viper.GetString("cluster"),
+		User:                  viper.GetString("user"),
+		Server:                viper.GetString("server"),
+		Token:                 viper.GetString("token"),
+		CertificateAuthority:  viper.GetString("certificate-authority"),
+		InsecureSkipTLSVerify: viper.GetBool("insecure-skip-tls-verify"),
+		Impersonate:           viper.GetString("as"),
+		ImpersonateGroups:     viper.GetStringSlice("as-group"),
+		ImpersonateUID:        viper.GetString("as-uid"),
+		Auth:                  viper.GetString("auth"),
+	}
 }
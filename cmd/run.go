@@ -1,26 +1,65 @@
 package cmd
 
 import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/h3poteto/kube-job/pkg/job"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/utils/ptr"
 )
 
 type runJob struct {
-	templateFile  string
-	name          string
-	args          string
-	image         string
-	resources     string
-	namespace     string
-	container     string
-	timeout       int
-	cleanup       string
-	ignoreSidecar bool
-	followLogs    bool
+	templateFile     string
+	name             string
+	args             string
+	image            string
+	resources        string
+	namespace        string
+	container        string
+	timeout          int
+	cleanup          string
+	ignoreSidecar    bool
+	sidecarShutdowns []string
+	followLogs       bool
+	matrixFile       string
+	forEach          []string
+	maxParallel      int
+	retries          int
+	retryOn          string
+	retryBackoff     int
+	idempotencyKey   string
+	onDuplicate      string
+	lock             string
+	lockWait         bool
+	nodeSelector     []string
+	tolerations      []string
+	affinityFile     string
+	priorityClass    string
+	serviceAccount   string
+	activeDeadline   int
+	backoffLimit     int
+	labels           []string
+	annotations      []string
+	podLabels        []string
+	podAnnotations   []string
+	skipPreflight    bool
+	overridePolicy   bool
+	yes              bool
+	env              []string
+	resolveDigest    string
+	files            []string
+	secretFiles      []string
+	secretEnv        []string
+	secretEnvFiles   []string
+	artifacts        []string
+	artifactImage    string
 }
 
 func runJobCmd() *cobra.Command {
@@ -28,7 +67,9 @@ func runJobCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "run",
 		Short: "Run a job on Kubernetes",
-		Run:   r.run,
+		// --resolve-digest has an optional value, so "--resolve-digest all" must not be taken as an argument.
+		Args: cobra.NoArgs,
+		Run:  r.run,
 	}
 
 	flags := cmd.Flags()
@@ -41,31 +82,283 @@ func runJobCmd() *cobra.Command {
 	flags.StringVar(&r.container, "container", "", "Container name where arguments will be substituted (in case of multiple in spec).")
 	flags.IntVarP(&r.timeout, "timeout", "t", 0, "Timeout seconds")
 	flags.StringVar(&r.cleanup, "cleanup", "all", "Cleanup completed job after run the job. You can specify 'all', 'succeeded' or 'failed'.")
+	flags.StringArrayVar(&r.env, "env", nil, "Environment variable of the target container, like KEY=VALUE. It can be specified multiple times.")
+	flags.StringArrayVar(&r.secretEnv, "secret-env", nil, "Name of the local environment variable which is passed to the target container with a Secret. The value is not written in the job. It can be specified multiple times.")
+	flags.StringArrayVar(&r.secretEnvFiles, "secret-env-file", nil, "Env file which has KEY=VALUE lines passed to the target container with a Secret. It can be specified multiple times.")
+	flags.StringArrayVar(&r.files, "file", nil, "Local file which is mounted into the target container with a ConfigMap, like LOCAL:MOUNT_PATH. It can be specified multiple times.")
+	flags.StringArrayVar(&r.secretFiles, "secret-file", nil, "Local file which is mounted into the target container with a Secret, like LOCAL:MOUNT_PATH. It can be specified multiple times.")
+	flags.StringArrayVar(&r.artifacts, "artifact", nil, "File or directory which is copied from the target container after it is terminated, like REMOTE_PATH:LOCAL_PATH. The parent directory of REMOTE_PATH is replaced with an empty volume. It can be specified multiple times.")
+	flags.StringVar(&r.artifactImage, "artifact-image", job.DefaultArtifactImage, "Image of the helper container which keeps the artifacts until they are copied. It needs sh, date, sleep, touch and tar.")
+	flags.StringVar(&r.resolveDigest, "resolve-digest", "", "Pin the images to the digests before creating the job, so retries run the same images. You can specify 'container' for the target container or 'all' for all containers.")
+	flags.Lookup("resolve-digest").NoOptDefVal = job.DigestContainer
 	flags.BoolVar(&r.ignoreSidecar, "ignore-sidecar", false, "Wait until all containers stop. If you set false, wait only specified container.")
+	flags.StringArrayVar(&r.sidecarShutdowns, "sidecar-shutdown", nil, "Stop a sidecar container after the target container is terminated. Specify NAME=http://localhost:PORT/PATH or NAME=exec:COMMAND. It can be specified multiple times.")
 	flags.BoolVar(&r.followLogs, "follow", true, "Specify if the logs should be streamed.")
+	flags.StringVar(&r.matrixFile, "matrix", "", "Matrix file which is a list of parameter sets. A job is run for each parameter set, and ${KEY} in args and env is replaced with the value.")
+	flags.StringArrayVar(&r.forEach, "for-each", nil, "Run a job for each value, like KEY=VALUE1,VALUE2. It can be specified multiple times.")
+	flags.IntVar(&r.retries, "retries", 0, "Maximum number of retries when the job is failed by a reason in --retry-on. A new job is created for each retry.")
+	flags.StringVar(&r.retryOn, "retry-on", "evicted,preempted", "Comma separated failure reasons to retry. You can specify 'evicted', 'oomkilled', 'preempted', 'deadline-exceeded' and 'exit-code=N'.")
+	flags.IntVar(&r.retryBackoff, "retry-backoff", 10, "Seconds to wait before the first retry. It is doubled for each retry, up to 5 minutes.")
+	flags.StringVar(&r.idempotencyKey, "idempotency-key", "", "Key to prevent duplicate runs. The job is labeled with a hash of the key.")
+	flags.StringVar(&r.onDuplicate, "on-duplicate", job.DuplicateAttach, "What to do when a job which has the same idempotency key exists. You can specify 'attach', 'reuse', 'fail' or 'run'.")
+	flags.StringVar(&r.lock, "lock", "", "Name of the lock which is acquired before running the job. Jobs which have the same lock do not run at the same time.")
+	flags.BoolVar(&r.lockWait, "lock-wait", false, "Wait until the lock is released. If you set false, exit with an error when the lock is held by someone else.")
+	flags.Int("ttl-after-finished", -1, "Seconds to remove the job by Kubernetes after the job is finished. It is a safety net when kube-job is killed before cleanup. If you don't set it, the value in the template is used.")
+	_ = viper.BindPFlag("ttl-after-finished", flags.Lookup("ttl-after-finished"))
+	flags.StringArrayVar(&r.nodeSelector, "node-selector", nil, "Node selector of the pod, like KEY=VALUE. It can be specified multiple times.")
+	flags.StringArrayVar(&r.tolerations, "toleration", nil, "Toleration of the pod, like KEY[=VALUE][:EFFECT]. It can be specified multiple times.")
+	flags.StringVar(&r.affinityFile, "affinity-file", "", "File which has the affinity of the pod")
+	flags.StringVar(&r.priorityClass, "priority-class", "", "Priority class name of the pod")
+	flags.StringVar(&r.serviceAccount, "service-account", "", "Service account name of the pod")
+	flags.IntVar(&r.activeDeadline, "active-deadline", 0, "Active deadline seconds of the job. If you don't set it, the value in the template is used.")
+	flags.IntVar(&r.backoffLimit, "backoff-limit", -1, "Backoff limit of the job. If you don't set it, the value in the template is used.")
+	flags.StringArrayVar(&r.labels, "label", nil, "Label of the job, like KEY=VALUE. It can be specified multiple times.")
+	flags.StringArrayVar(&r.annotations, "annotation", nil, "Annotation of the job, like KEY=VALUE. It can be specified multiple times.")
+	flags.StringArrayVar(&r.podLabels, "pod-label", nil, "Label of the pod, like KEY=VALUE. It can be specified multiple times.")
+	flags.StringArrayVar(&r.podAnnotations, "pod-annotation", nil, "Annotation of the pod, like KEY=VALUE. It can be specified multiple times.")
+	flags.IntVar(&r.maxParallel, "max-parallel", 1, "Maximum number of jobs which run at the same time with --matrix or --for-each.")
+	flags.BoolVar(&r.skipPreflight, "skip-preflight", false, "Skip checking permissions to run the job before creating it.")
+	flags.String("policy-file", "", "Policy file which has rules the job must satisfy before it is created.")
+	_ = viper.BindPFlag("policy-file", flags.Lookup("policy-file"))
+	flags.BoolVarP(&r.yes, "yes", "y", false, "Run the job without confirmation in protected contexts and namespaces.")
+	flags.BoolVar(&r.overridePolicy, "override-policy", false, "Create the job even if it violates the policy. The violations are recorded in the annotation.")
 
 	return cmd
 }
 
 func (r *runJob) run(cmd *cobra.Command, args []string) {
-	config, verbose := generalConfig()
-	log.SetLevel(log.DebugLevel)
-	if !verbose {
-		log.SetLevel(log.WarnLevel)
-	}
+	config := generalConfig()
 	if r.cleanup != job.All.String() && r.cleanup != job.Succeeded.String() && r.cleanup != job.Failed.String() {
 		err := errors.New("please set 'all', 'succeeded' or 'failed' as --cleanup")
-		log.Fatal(err)
+		fatal(err)
 	}
 
-	log.Infof("Using config file: %s", config)
-	j, err := job.NewJob(config, r.templateFile, r.name, r.args, r.image, r.resources, r.namespace, r.container, (time.Duration(r.timeout) * time.Second))
+	slog.Info("Using config file", "path", config.Path)
+	resources, err := job.ParseResources(r.resources)
+	if err != nil {
+		fatal(err)
+	}
+	env, err := job.ParseKeyValues(r.env)
+	if err != nil {
+		fatal(err)
+	}
+	files, err := r.mountedFiles()
+	if err != nil {
+		fatal(err)
+	}
+	secretEnv, err := r.parseSecretEnv()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+	artifacts := []job.Artifact{}
+	for _, value := range r.artifacts {
+		artifact, err := job.ParseArtifact(value)
+		if err != nil {
+			fatal(err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	j, err := job.New(
+		cmd.Context(),
+		job.WithKubeconfigOptions(config),
+		job.WithTemplate(r.templateFile),
+		job.WithName(r.name),
+		job.WithArgsString(r.args),
+		job.WithImage(r.image),
+		job.WithResources(resources),
+		job.WithNamespace(r.namespace),
+		job.WithContainer(r.container),
+		job.WithEnv(env),
+		job.WithResolveDigest(r.resolveDigest),
+		job.WithFiles(files),
+		job.WithSecretEnv(secretEnv),
+		job.WithArtifacts(artifacts, r.artifactImage),
+		job.WithTimeout(time.Duration(r.timeout)*time.Second),
+		job.WithLogger(slog.Default()),
+		job.WithEventHandler(job.EventHandlerFunc(logEvent)),
+		job.WithSkipPreflight(r.skipPreflight),
+	)
+	if err != nil {
+		fatal(err)
+	}
+	for _, s := range r.sidecarShutdowns {
+		sidecar, err := job.ParseSidecarShutdown(s)
+		if err != nil {
+			fatal(err)
+		}
+		j.SidecarShutdowns = append(j.SidecarShutdowns, sidecar)
+	}
+	retryOn, err := job.ParseRetryOn(r.retryOn)
+	if err != nil {
+		fatal(err)
+	}
+	j.IdempotencyKey = r.idempotencyKey
+	j.OnDuplicate = r.onDuplicate
+	j.Lock = r.lock
+	j.LockWait = r.lockWait
+	if ttl := viper.GetInt("ttl-after-finished"); ttl >= 0 {
+		j.TTLAfterFinished = ptr.To(int32(ttl))
+	}
+	policy, err := loadPolicy(viper.GetString("policy-file"))
+	if err != nil {
+		fatal(err)
+	}
+	j.Policy = policy
+	j.OverridePolicy = r.overridePolicy
+	if err := r.applyScheduling(j); err != nil {
+		fatal(err)
+	}
+	if err := r.applyMetadata(j); err != nil {
+		fatal(err)
+	}
+	j.Retry = job.RetryPolicy{
+		Retries: r.retries,
+		On:      retryOn,
+		Backoff: time.Duration(r.retryBackoff) * time.Second,
+	}
+
+	matrix, err := r.parseMatrix()
+	if err != nil {
+		fatal(err)
+	}
+	if err := r.confirm(config, j); err != nil {
+		fatal(err)
+	}
+	if len(matrix) > 0 {
+		results := j.RunMatrix(cmd.Context(), matrix, r.maxParallel, r.cleanup, r.ignoreSidecar, r.followLogs)
+		if err := summarizeMatrix(results); err != nil {
+			fatal(err)
+		}
+		return
 	}
 
-	if err := j.RunAndCleanup(r.cleanup, r.ignoreSidecar, r.followLogs); err != nil {
-		log.Fatal(err)
+	if err := j.RunAndCleanup(cmd.Context(), r.cleanup, r.ignoreSidecar, r.followLogs); err != nil {
+		fatal(err)
 	}
 
 }
+
+func (r *runJob) applyScheduling(j *job.Job) error {
+	nodeSelector, err := job.ParseNodeSelector(r.nodeSelector)
+	if err != nil {
+		return err
+	}
+	j.NodeSelector = nodeSelector
+	for _, t := range r.tolerations {
+		toleration, err := job.ParseToleration(t)
+		if err != nil {
+			return err
+		}
+		j.Tolerations = append(j.Tolerations, toleration)
+	}
+	if len(r.affinityFile) > 0 {
+		affinity, err := job.LoadAffinity(r.affinityFile)
+		if err != nil {
+			return err
+		}
+		j.Affinity = affinity
+	}
+	j.PriorityClassName = r.priorityClass
+	j.ServiceAccountName = r.serviceAccount
+	if r.activeDeadline != 0 {
+		j.ActiveDeadlineSeconds = ptr.To(int64(r.activeDeadline))
+	}
+	if r.backoffLimit != -1 {
+		j.BackoffLimit = ptr.To(int32(r.backoffLimit))
+	}
+	return nil
+}
+
+func (r *runJob) applyMetadata(j *job.Job) error {
+	var err error
+	if j.Labels, err = job.ParseKeyValues(r.labels); err != nil {
+		return err
+	}
+	if j.Annotations, err = job.ParseKeyValues(r.annotations); err != nil {
+		return err
+	}
+	if j.PodLabels, err = job.ParseKeyValues(r.podLabels); err != nil {
+		return err
+	}
+	if j.PodAnnotations, err = job.ParseKeyValues(r.podAnnotations); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseSecretEnv reads the env files in --secret-env-file, and the local environment variables in --secret-env.
+func (r *runJob) parseSecretEnv() (map[string]string, error) {
+	env := map[string]string{}
+	for _, file := range r.secretEnvFiles {
+		values, err := job.ParseEnvFile(file)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(env, values)
+	}
+	for _, name := range r.secretEnv {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+		env[name] = value
+	}
+	return env, nil
+}
+
+// mountedFiles reads the files in --file and --secret-file.
+func (r *runJob) mountedFiles() ([]job.MountedFile, error) {
+	files := []job.MountedFile{}
+	for _, secret := range []bool{false, true} {
+		values := r.files
+		if secret {
+			values = r.secretFiles
+		}
+		for _, value := range values {
+			file, err := job.ParseMountedFile(value, secret)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func (r *runJob) parseMatrix() ([]job.Parameters, error) {
+	if len(r.matrixFile) > 0 && len(r.forEach) > 0 {
+		return nil, errors.New("please set either --matrix or --for-each")
+	}
+	if len(r.matrixFile) > 0 {
+		return job.LoadMatrix(r.matrixFile)
+	}
+	if len(r.forEach) > 0 {
+		return job.ParseForEach(r.forEach)
+	}
+	return nil, nil
+}
+
+// loadPolicy reads the policy file. It returns nil if the file is not specified.
+func loadPolicy(file string) (*job.Policy, error) {
+	if len(file) == 0 {
+		return nil, nil
+	}
+	return job.LoadPolicy(file)
+}
+
+// summarizeMatrix prints the result of each job, and returns error if any job is failed.
+func summarizeMatrix(results []job.MatrixResult) error {
+	failed := []string{}
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(os.Stderr, "FAILED    %s (%s): %v\n", result.Parameters, result.JobName, result.Err)
+			failed = append(failed, result.Parameters.String())
+		} else {
+			fmt.Fprintf(os.Stderr, "SUCCEEDED %s (%s)\n", result.Parameters, result.JobName)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d jobs are failed: %s", len(failed), len(results), strings.Join(failed, "; "))
+	}
+	return nil
+}
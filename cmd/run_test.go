@@ -0,0 +1,16 @@
+package cmd
+
+import "testing"
+
+func TestRunJobCmdArgs(t *testing.T) {
+	cmd := runJobCmd()
+	if err := cmd.Flags().Parse([]string{"--resolve-digest", "all"}); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := cmd.Flags().GetString("resolve-digest"); value != "container" {
+		t.Errorf("--resolve-digest without = should use the default value: %s", value)
+	}
+	if err := cmd.ValidateArgs(cmd.Flags().Args()); err == nil {
+		t.Error("positional argument should be error")
+	}
+}
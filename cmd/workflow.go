@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/h3poteto/kube-job/pkg/job"
+	"github.com/spf13/cobra"
+)
+
+type workflow struct {
+	file           string
+	followLogs     bool
+	policyFile     string
+	overridePolicy bool
+	yes            bool
+}
+
+func workflowCmd() *cobra.Command {
+	w := &workflow{}
+	cmd := &cobra.Command{
+		Use:   "workflow",
+		Short: "Run jobs which depend on each other on Kubernetes",
+		Run:   w.run,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&w.file, "file", "f", "", "Workflow file")
+	flags.BoolVar(&w.followLogs, "follow", true, "Specify if the logs should be streamed.")
+	flags.StringVar(&w.policyFile, "policy-file", "", "Policy file which has rules every step must satisfy before the job is created.")
+	flags.BoolVarP(&w.yes, "yes", "y", false, "Run the workflow without confirmation in protected contexts and namespaces.")
+	flags.BoolVar(&w.overridePolicy, "override-policy", false, "Create the jobs even if they violate the policy. The violations are recorded in the annotation.")
+
+	return cmd
+}
+
+func (w *workflow) run(cmd *cobra.Command, args []string) {
+	config := generalConfig()
+
+	slog.Info("Using config file", "path", config.Path)
+	wf, err := job.LoadWorkflow(w.file)
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := w.confirm(cmd.Context(), config, wf); err != nil {
+		fatal(err)
+	}
+	policy, err := loadPolicy(w.policyFile)
+	if err != nil {
+		fatal(err)
+	}
+
+	wf.Logger = slog.Default()
+	wf.Events = job.EventHandlerFunc(logEvent)
+	wf.Policy = policy
+	wf.OverridePolicy = w.overridePolicy
+	results := wf.Run(cmd.Context(), config, w.followLogs)
+	if err := summarizeWorkflow(results); err != nil {
+		fatal(err)
+	}
+}
+
+// confirm asks the user to type each protected namespace before any step is run.
+func (w *workflow) confirm(ctx context.Context, config job.KubeconfigOptions, wf *job.Workflow) error {
+	if w.yes {
+		return nil
+	}
+	kubeContext, err := job.ResolveContext(config)
+	if err != nil {
+		return err
+	}
+	namespaces, err := wf.Namespaces(ctx, config)
+	if err != nil {
+		return err
+	}
+	steps := map[string][]string{}
+	order := []string{}
+	for i, namespace := range namespaces {
+		if _, ok := steps[namespace]; !ok {
+			order = append(order, namespace)
+		}
+		steps[namespace] = append(steps[namespace], wf.Steps[i].Name)
+	}
+	for _, namespace := range order {
+		err := confirmNamespace(kubeContext, namespace, func(out io.Writer) error {
+			fmt.Fprintf(out, "Context:   %s\n", kubeContext.Name)
+			fmt.Fprintf(out, "Cluster:   %s (%s)\n", kubeContext.Cluster, kubeContext.Server)
+			fmt.Fprintf(out, "Namespace: %s\n", namespace)
+			fmt.Fprintf(out, "Steps:     %s\n", strings.Join(steps[namespace], ", "))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summarizeWorkflow prints the result of each step, and returns error if any step is failed.
+func summarizeWorkflow(results []job.StepResult) error {
+	failed := []string{}
+	for _, result := range results {
+		switch result.Status {
+		case job.StepFailed:
+			fmt.Fprintf(os.Stderr, "%-9s %s (%s, %s): %v\n", result.Status, result.Name, result.JobName, result.Duration.Round(time.Second), result.Err)
+			failed = append(failed, result.Name)
+		case job.StepSkipped:
+			fmt.Fprintf(os.Stderr, "%-9s %s\n", result.Status, result.Name)
+		default:
+			fmt.Fprintf(os.Stderr, "%-9s %s (%s, %s)\n", result.Status, result.Name, result.JobName, result.Duration.Round(time.Second))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d steps are failed: %v", len(failed), len(results), failed)
+	}
+	return nil
+}
@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/h3poteto/kube-job/cmd"
 )
 
 func main() {
-	if err := cmd.RootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// Restore the default behavior after the first signal, so a second signal kills the process
+		// even while the job is cleaned up.
+		<-ctx.Done()
+		stop()
+	}()
+	if err := cmd.RootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(-1)
 	}
@@ -0,0 +1,260 @@
+package job
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DefaultArtifactImage is the image of the helper container which keeps the artifacts until they are copied.
+// It needs sh, sleep, date, touch and tar.
+const DefaultArtifactImage = "busybox:1.36"
+
+const (
+	// artifactsMaxWait is how long the helper container waits for the artifacts to be copied
+	// when neither the timeout nor activeDeadlineSeconds is set.
+	artifactsMaxWait = 24 * time.Hour
+
+	artifactsVolume    = "kube-job-artifacts"
+	artifactsContainer = "kube-job-artifacts"
+	artifactsMountPath = "/kube-job-artifacts"
+	// artifactsDone is the file which tells the helper container to exit.
+	artifactsDone = artifactsMountPath + "/.done"
+)
+
+// Artifact is a file or a directory in the target container which is copied to the local machine
+// after the target container is terminated.
+type Artifact struct {
+	// Absolute path in the target container. The parent directory is replaced with an empty volume,
+	// which is shared with the helper container.
+	Path string
+	// Local path which the artifact is copied to.
+	Destination string
+}
+
+// ParseArtifact parses an artifact in REMOTE_PATH:LOCAL_PATH.
+func ParseArtifact(value string) (Artifact, error) {
+	remote, local, found := strings.Cut(value, ":")
+	if !found || len(remote) == 0 || len(local) == 0 {
+		return Artifact{}, fmt.Errorf("Invalid artifact %q, it must be REMOTE_PATH:LOCAL_PATH", value)
+	}
+	return Artifact{Path: remote, Destination: local}, nil
+}
+
+func validateArtifacts(artifacts []Artifact) error {
+	for _, artifact := range artifacts {
+		if !path.IsAbs(artifact.Path) {
+			return fmt.Errorf("Artifact path %q must be absolute", artifact.Path)
+		}
+		if path.Dir(path.Clean(artifact.Path)) == "/" {
+			return fmt.Errorf("Artifact %s must be in a directory, because the directory is replaced with a volume", artifact.Path)
+		}
+	}
+	return nil
+}
+
+// artifactDirs returns the parent directories of the artifacts. Each directory is mounted with subPath dir-N.
+func (j *Job) artifactDirs() []string {
+	dirs := []string{}
+	for _, artifact := range j.Artifacts {
+		dir := path.Dir(path.Clean(artifact.Path))
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// artifactSource returns the path of the artifact in the helper container.
+func (j *Job) artifactSource(artifact Artifact) (string, string) {
+	clean := path.Clean(artifact.Path)
+	for i, dir := range j.artifactDirs() {
+		if dir == path.Dir(clean) {
+			return path.Join(artifactsMountPath, fmt.Sprintf("dir-%d", i)), path.Base(clean)
+		}
+	}
+	return "", ""
+}
+
+// applyArtifacts mounts the shared volume into the directories of the artifacts, and adds the helper container.
+// The helper container waits until the artifacts are copied, or the deadline of the job.
+func (j *Job) applyArtifacts(job *v1.Job, index int) error {
+	podSpec := &job.Spec.Template.Spec
+	container := &podSpec.Containers[index]
+	for i, dir := range j.artifactDirs() {
+		if err := checkArtifactDir(container, dir); err != nil {
+			return err
+		}
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      artifactsVolume,
+			MountPath: dir,
+			SubPath:   fmt.Sprintf("dir-%d", i),
+		})
+	}
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name:         artifactsVolume,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+
+	// Exit even if kube-job is killed before copying the artifacts, so the job can finish.
+	wait := artifactsMaxWait
+	if j.Timeout > 0 {
+		wait = j.Timeout
+	} else if job.Spec.ActiveDeadlineSeconds != nil {
+		wait = time.Duration(*job.Spec.ActiveDeadlineSeconds) * time.Second
+	}
+	seconds := int((wait + cleanupTimeout).Seconds())
+	command := fmt.Sprintf("end=$(( $(date +%%s) + %d )); until [ -f %s ] || [ $(date +%%s) -ge $end ]; do sleep 1; done", seconds, artifactsDone)
+	image := j.ArtifactImage
+	if len(image) == 0 {
+		image = DefaultArtifactImage
+	}
+	podSpec.Containers = append(podSpec.Containers, corev1.Container{
+		Name:    artifactsContainer,
+		Image:   image,
+		Command: []string{"sh", "-c", command},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: artifactsVolume, MountPath: artifactsMountPath},
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("16Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("64Mi"),
+			},
+		},
+	})
+	return nil
+}
+
+// checkArtifactDir checks that the directory which is replaced with the volume does not hide
+// the working directory or the other volumes of the container.
+func checkArtifactDir(container *corev1.Container, dir string) error {
+	if len(container.WorkingDir) > 0 && isSameOrUnder(path.Clean(container.WorkingDir), dir) {
+		return fmt.Errorf("Artifact directory %s can not be used, because it hides the working directory %s", dir, container.WorkingDir)
+	}
+	for _, mount := range container.VolumeMounts {
+		mountPath := path.Clean(mount.MountPath)
+		if isSameOrUnder(mountPath, dir) || isSameOrUnder(dir, mountPath) {
+			return fmt.Errorf("Artifact directory %s can not be used, because it overlaps the volume %s at %s", dir, mount.Name, mount.MountPath)
+		}
+	}
+	return nil
+}
+
+// isSameOrUnder checks whether p is dir or in dir.
+func isSameOrUnder(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// collectArtifacts copies the artifacts from the pods where the target container is terminated, and stops the helper containers.
+// Pods which are already collected are skipped, and they are recorded in collected.
+func (j *Job) collectArtifacts(ctx context.Context, pods []corev1.Pod, containerName string, collected map[string]bool) error {
+	var lastErr error
+	for _, pod := range pods {
+		if collected[pod.Name] || !containerIsRunning(pod, artifactsContainer) {
+			continue
+		}
+		if finished, _ := containerIsCompleted(pod, containerName); !finished {
+			continue
+		}
+		collected[pod.Name] = true
+		for _, artifact := range j.Artifacts {
+			j.logger().Info("Copying the artifact", "pod", pod.Name, "path", artifact.Path, "destination", artifact.Destination)
+			if err := j.copyArtifact(ctx, pod, artifact); err != nil {
+				j.logger().Warn("Failed to copy the artifact", "pod", pod.Name, "path", artifact.Path, "error", err)
+				lastErr = errors.Wrapf(err, "Failed to copy the artifact %s", artifact.Path)
+			}
+		}
+		if err := j.execInContainer(ctx, pod, artifactsContainer, []string{"touch", artifactsDone}); err != nil {
+			j.logger().Warn("Failed to stop the helper container of the artifacts", "pod", pod.Name, "error", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// copyArtifact streams the artifact with tar in the helper container, and extracts it to the destination.
+func (j *Job) copyArtifact(ctx context.Context, pod corev1.Pod, artifact Artifact) error {
+	dir, base := j.artifactSource(artifact)
+	reader, writer := io.Pipe()
+	go func() {
+		err := j.execStream(ctx, pod, artifactsContainer, []string{"tar", "cf", "-", "-C", dir, base}, writer)
+		writer.CloseWithError(err)
+	}()
+	err := extractArtifact(reader, base, artifact.Destination)
+	reader.CloseWithError(err)
+	return err
+}
+
+// extractArtifact extracts the file or the directory named base in the tar stream to the destination.
+func extractArtifact(r io.Reader, base, destination string) error {
+	found := false
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		rel, ok := strings.CutPrefix(strings.TrimSuffix(header.Name, "/"), base)
+		if !ok || (len(rel) > 0 && rel[0] != '/') {
+			continue
+		}
+		rel = strings.TrimPrefix(rel, "/")
+		if len(rel) > 0 && !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return fmt.Errorf("Invalid path %s in the artifact", header.Name)
+		}
+		target := filepath.Join(destination, filepath.FromSlash(rel))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeArtifactFile(target, tr, header.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			// Links and special files are not copied.
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("%s is not found in the artifact", base)
+	}
+	return nil
+}
+
+func writeArtifactFile(target string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
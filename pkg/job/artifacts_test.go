@@ -0,0 +1,168 @@
+package job
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func TestParseArtifact(t *testing.T) {
+	artifact, err := ParseArtifact("/out/report.csv:./report.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artifact.Path != "/out/report.csv" || artifact.Destination != "./report.csv" {
+		t.Errorf("artifact is not parsed: %v", artifact)
+	}
+	if _, err := ParseArtifact("/out/report.csv"); err == nil {
+		t.Error("artifact without destination should be error")
+	}
+	if err := validateArtifacts([]Artifact{{Path: "out/report.csv", Destination: "report.csv"}}); err == nil {
+		t.Error("relative path should be error")
+	}
+	if err := validateArtifacts([]Artifact{{Path: "/report.csv", Destination: "report.csv"}}); err == nil {
+		t.Error("artifact in the root directory should be error")
+	}
+}
+
+func TestBuildJobWithArtifacts(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{
+		CurrentJob: currentJob,
+		Timeout:    10 * time.Minute,
+		Artifacts: []Artifact{
+			{Path: "/out/report.csv", Destination: "report.csv"},
+			{Path: "/out/dump/", Destination: "dump"},
+			{Path: "/tmp/result.json", Destination: "result.json"},
+		},
+	}
+	j, err := job.BuildJob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers := j.Spec.Template.Spec.Containers
+	if len(containers) != 2 || containers[1].Name != artifactsContainer || containers[1].Image != DefaultArtifactImage {
+		t.Fatalf("helper container is not added: %v", containers)
+	}
+	if !strings.Contains(containers[1].Command[2], "+ 660 ))") {
+		t.Errorf("helper container should wait until the timeout: %s", containers[1].Command[2])
+	}
+	mounts := containers[0].VolumeMounts
+	if len(mounts) != 2 || mounts[0].MountPath != "/out" || mounts[0].SubPath != "dir-0" || mounts[1].MountPath != "/tmp" || mounts[1].SubPath != "dir-1" {
+		t.Errorf("directories of the artifacts are not mounted: %v", mounts)
+	}
+	if dir, base := job.artifactSource(job.Artifacts[1]); dir != "/kube-job-artifacts/dir-0" || base != "dump" {
+		t.Errorf("source of the artifact is wrong: %s, %s", dir, base)
+	}
+	if name, err := job.targetContainerName(j); err != nil || name != "alpine" {
+		t.Errorf("target container should not be the helper container: %s, %v", name, err)
+	}
+
+	job.Timeout = 0
+	job.ActiveDeadlineSeconds = ptr.To(int64(300))
+	if j, err = job.BuildJob(); err != nil || !strings.Contains(j.Spec.Template.Spec.Containers[1].Command[2], "+ 360 ))") {
+		t.Errorf("helper container should wait until the active deadline: %v", err)
+	}
+	job.ActiveDeadlineSeconds = nil
+	if j, err = job.BuildJob(); err != nil || !strings.Contains(j.Spec.Template.Spec.Containers[1].Command[2], "+ 86460 ))") {
+		t.Errorf("helper container should wait until the default limit: %v", err)
+	}
+}
+
+func TestBuildJobWithOverlappingArtifacts(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := &currentJob.Spec.Template.Spec.Containers[0]
+	container.WorkingDir = "/app/src"
+	container.VolumeMounts = []corev1.VolumeMount{{Name: "cache", MountPath: "/cache/"}}
+	job := &Job{CurrentJob: currentJob}
+	for _, artifact := range []string{"/app/report.csv", "/app/src/report.csv", "/cache/report.csv", "/cache/out/report.csv", "/report/out.csv"} {
+		job.Artifacts = []Artifact{{Path: artifact, Destination: "report.csv"}}
+		_, err := job.BuildJob()
+		if artifact == "/report/out.csv" && err != nil {
+			t.Errorf("%s should be allowed: %v", artifact, err)
+		} else if artifact != "/report/out.csv" && err == nil {
+			t.Errorf("%s should be error, because it overlaps the working directory or the volume", artifact)
+		}
+	}
+}
+
+func TestWaitJobCompleteWithoutArtifacts(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished := currentJob.DeepCopy()
+	finished.Status.Succeeded = 1
+	job := &Job{
+		CurrentJob: currentJob,
+		Artifacts:  []Artifact{{Path: "/out/report.csv", Destination: "report.csv"}},
+		client:     fake.NewClientset(finished),
+	}
+	if err := job.WaitJobComplete(context.Background(), finished, false); err == nil || !strings.Contains(err.Error(), "Artifacts are not copied") {
+		t.Errorf("job which is finished without copying the artifacts should be error: %v", err)
+	}
+}
+
+func writeTar(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(name, "/") {
+			header = &tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractArtifact(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "report.csv")
+	if err := extractArtifact(writeTar(t, map[string]string{"report.csv": "a,b\n"}), "report.csv", file); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "a,b\n" {
+		t.Errorf("file is not extracted: %q, %v", data, err)
+	}
+
+	dump := filepath.Join(dir, "dump")
+	archive := writeTar(t, map[string]string{"dump/": "", "dump/sub/table.sql": "CREATE TABLE"})
+	if err := extractArtifact(archive, "dump", dump); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dump, "sub", "table.sql")); err != nil || string(data) != "CREATE TABLE" {
+		t.Errorf("directory is not extracted: %q, %v", data, err)
+	}
+
+	if err := extractArtifact(writeTar(t, map[string]string{"dump/../../evil": "x"}), "dump", dump); err == nil {
+		t.Error("path outside of the destination should be error")
+	}
+	if err := extractArtifact(writeTar(t, map[string]string{"other.csv": "x"}), "report.csv", file); err == nil {
+		t.Error("missing artifact should be error")
+	}
+}
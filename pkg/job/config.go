@@ -1,29 +1,231 @@
 package job
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
-func newClient(configFile string) (*kubernetes.Clientset, error) {
-	_, err := os.Stat(configFile)
+const (
+	// AuthAuto uses the kubeconfig if it exists, otherwise in-cluster config.
+	AuthAuto = "auto"
+	// AuthKubeconfig always uses the kubeconfig, and returns error if it does not exist.
+	AuthKubeconfig = "kubeconfig"
+	// AuthInCluster always uses in-cluster config, and ignores the kubeconfig.
+	AuthInCluster = "in-cluster"
+)
+
+// inClusterNamespaceFile has the namespace of the service account in the pod.
+const inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// KubeconfigOptions selects the context, the cluster and the user in the kubeconfig, and overrides them.
+// Empty fields are not overridden.
+type KubeconfigOptions struct {
+	// Path of the kubeconfig file. Colon-separated paths are merged like KUBECONFIG.
+	// Environment variables in the path are expanded.
+	Path string
+	// Name of the context. If you set empty, current-context is used.
+	Context string
+	// Name of the cluster in the kubeconfig.
+	Cluster string
+	// Name of the user in the kubeconfig.
+	User string
+	// Address of the API server.
+	Server string
+	// Bearer token for authentication to the API server.
+	Token string
+	// Path of the certificate file for the certificate authority.
+	CertificateAuthority string
+	// If you set true, the certificate of the API server is not checked.
+	InsecureSkipTLSVerify bool
+	// User name to impersonate.
+	Impersonate string
+	// Groups to impersonate.
+	ImpersonateGroups []string
+	// UID to impersonate.
+	ImpersonateUID string
+	// Auto, kubeconfig or in-cluster. If you set empty, auto is used.
+	Auth string
+}
+
+// NewClient returns a kubernetes client from the config file.
+// If the config file does not exist, in-cluster config is used.
+func NewClient(configFile string) (kubernetes.Interface, error) {
+	client, _, err := NewClientWithKubeconfig(KubeconfigOptions{Path: configFile})
+	return client, err
+}
+
+// NewClientWithKubeconfig returns a kubernetes client and the namespace of the context.
+// Which config is used depends on the Auth of the options.
+func NewClientWithKubeconfig(options KubeconfigOptions) (kubernetes.Interface, string, error) {
+	client, _, namespace, err := newClient(options)
+	return client, namespace, err
+}
+
+func newClient(options KubeconfigOptions) (*kubernetes.Clientset, *rest.Config, string, error) {
+	kubeConfig, namespace, err := newRestConfig(options)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	client, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return client, kubeConfig, namespace, nil
+}
+
+// KubeconfigContext describes where the client connects to.
+type KubeconfigContext struct {
+	// Name of the context. It is empty with in-cluster config.
+	Name string
+	// Name of the cluster. It is "in-cluster" with in-cluster config.
+	Cluster string
+	// Address of the API server.
+	Server string
+}
+
+// ResolveContext returns the context which is used by the client created with the options.
+func ResolveContext(options KubeconfigOptions) (KubeconfigContext, error) {
+	paths := kubeconfigPaths(options.Path)
+	inCluster, err := useInClusterConfig(paths, options.Auth)
 	if err != nil {
-		kubeConfig, err := rest.InClusterConfig()
+		return KubeconfigContext{}, err
+	}
+	if inCluster {
+		kubeConfig, _, err := newInClusterConfig(options)
 		if err != nil {
-			return nil, err
+			return KubeconfigContext{}, err
+		}
+		return KubeconfigContext{Cluster: "in-cluster", Server: kubeConfig.Host}, nil
+	}
+	clientConfig := newClientConfig(paths, options)
+	kubeConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return KubeconfigContext{}, err
+	}
+	raw, err := clientConfig.RawConfig()
+	if err != nil {
+		return KubeconfigContext{}, err
+	}
+	resolved := KubeconfigContext{
+		Name:    raw.CurrentContext,
+		Cluster: options.Cluster,
+		Server:  kubeConfig.Host,
+	}
+	if len(options.Context) > 0 {
+		resolved.Name = options.Context
+	}
+	if c, ok := raw.Contexts[resolved.Name]; ok && len(resolved.Cluster) == 0 {
+		resolved.Cluster = c.Cluster
+	}
+	return resolved, nil
+}
+
+// useInClusterConfig decides whether in-cluster config is used according to the auth mode.
+func useInClusterConfig(paths []string, auth string) (bool, error) {
+	switch auth {
+	case AuthInCluster:
+		return true, nil
+	case AuthKubeconfig:
+		if !anyFileExists(paths) {
+			return false, fmt.Errorf("Kubeconfig %s does not exist", strings.Join(paths, string(filepath.ListSeparator)))
 		}
+		return false, nil
+	case "", AuthAuto:
+		return !anyFileExists(paths), nil
+	}
+	return false, fmt.Errorf("Invalid auth %q, please set 'auto', 'kubeconfig' or 'in-cluster'", auth)
+}
+
+// newRestConfig returns the rest config and the namespace according to the auth mode.
+func newRestConfig(options KubeconfigOptions) (*rest.Config, string, error) {
+	paths := kubeconfigPaths(options.Path)
+	inCluster, err := useInClusterConfig(paths, options.Auth)
+	if err != nil {
+		return nil, "", err
+	}
+	if inCluster {
+		return newInClusterConfig(options)
+	}
+
+	clientConfig := newClientConfig(paths, options)
+	kubeConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, "", err
+	}
+	return kubeConfig, namespace, nil
+}
 
-		return kubernetes.NewForConfig(kubeConfig)
+// kubeconfigPaths splits the colon-separated paths. If the path is empty, KUBECONFIG or ~/.kube/config is used.
+func kubeconfigPaths(path string) []string {
+	if path = os.ExpandEnv(path); len(path) > 0 {
+		return filepath.SplitList(path)
 	}
+	return clientcmd.NewDefaultClientConfigLoadingRules().GetLoadingPrecedence()
+}
 
-	kubeConfig, err := clientcmd.BuildConfigFromFlags("", configFile)
+func anyFileExists(paths []string) bool {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// newInClusterConfig returns the config of the service account, and the namespace of the pod.
+func newInClusterConfig(options KubeconfigOptions) (*rest.Config, string, error) {
+	kubeConfig, err := rest.InClusterConfig()
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	kubeConfig.Impersonate = rest.ImpersonationConfig{
+		UserName: options.Impersonate,
+		Groups:   options.ImpersonateGroups,
+		UID:      options.ImpersonateUID,
+	}
+	namespace := "default"
+	if data, err := os.ReadFile(inClusterNamespaceFile); err == nil {
+		if ns := strings.TrimSpace(string(data)); len(ns) > 0 {
+			namespace = ns
+		}
 	}
+	return kubeConfig, namespace, nil
+}
 
-	return kubernetes.NewForConfig(kubeConfig)
+// newClientConfig loads the kubeconfig files lazily with the overrides.
+func newClientConfig(paths []string, options KubeconfigOptions) clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.Precedence = paths
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: options.Context,
+		Context: clientcmdapi.Context{
+			Cluster:  options.Cluster,
+			AuthInfo: options.User,
+		},
+		ClusterInfo: clientcmdapi.Cluster{
+			Server:                options.Server,
+			CertificateAuthority:  options.CertificateAuthority,
+			InsecureSkipTLSVerify: options.InsecureSkipTLSVerify,
+		},
+		AuthInfo: clientcmdapi.AuthInfo{
+			Token:             options.Token,
+			Impersonate:       options.Impersonate,
+			ImpersonateGroups: options.ImpersonateGroups,
+			ImpersonateUID:    options.ImpersonateUID,
+		},
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
 }
@@ -0,0 +1,189 @@
+package job
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/batch/v1"
+	"k8s.io/client-go/rest"
+)
+
+const firstKubeconfig = `apiVersion: v1
+kind: Config
+current-context: first
+clusters:
+- name: first
+  cluster:
+    server: https://first.example.com
+contexts:
+- name: first
+  context:
+    cluster: first
+    user: first
+users:
+- name: first
+  user:
+    token: first-token
+`
+
+const secondKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: second
+  cluster:
+    server: https://second.example.com
+contexts:
+- name: second
+  context:
+    cluster: second
+    user: second
+    namespace: batch
+users:
+- name: second
+  user:
+    token: second-token
+`
+
+func writeKubeconfigs(t *testing.T) string {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	if err := os.WriteFile(first, []byte(firstKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte(secondKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return first + string(filepath.ListSeparator) + second
+}
+
+func TestNewClientWithMergedKubeconfig(t *testing.T) {
+	path := writeKubeconfigs(t)
+
+	_, restConfig, namespace, err := newClient(KubeconfigOptions{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restConfig.Host != "https://first.example.com" || namespace != "default" {
+		t.Errorf("current-context is not used: %s, %s", restConfig.Host, namespace)
+	}
+
+	_, restConfig, namespace, err = newClient(KubeconfigOptions{Path: path, Context: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restConfig.Host != "https://second.example.com" || restConfig.BearerToken != "second-token" || namespace != "batch" {
+		t.Errorf("context in the second file is not used: %s, %s, %s", restConfig.Host, restConfig.BearerToken, namespace)
+	}
+}
+
+func TestNewClientWithOverrides(t *testing.T) {
+	path := writeKubeconfigs(t)
+
+	_, restConfig, _, err := newClient(KubeconfigOptions{
+		Path:    path,
+		Cluster: "second",
+		User:    "second",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restConfig.Host != "https://second.example.com" || restConfig.BearerToken != "second-token" {
+		t.Errorf("cluster and user are not overridden: %s, %s", restConfig.Host, restConfig.BearerToken)
+	}
+
+	_, restConfig, _, err = newClient(KubeconfigOptions{
+		Path:                  path,
+		Server:                "https://override.example.com",
+		Token:                 "override-token",
+		InsecureSkipTLSVerify: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restConfig.Host != "https://override.example.com" || restConfig.BearerToken != "override-token" || !restConfig.Insecure {
+		t.Errorf("server and token are not overridden: %v", restConfig)
+	}
+}
+
+func TestNewDefaultsNamespaceFromContext(t *testing.T) {
+	path := writeKubeconfigs(t)
+	template := &v1.Job{}
+	template.Name = "example-job"
+	j, err := New(
+		context.Background(),
+		WithKubeconfigOptions(KubeconfigOptions{Path: path, Context: "second"}),
+		WithTemplateJob(template),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.CurrentJob.Namespace != "batch" {
+		t.Errorf("namespace of the context is not used: %s", j.CurrentJob.Namespace)
+	}
+}
+
+func TestNewClientWithImpersonation(t *testing.T) {
+	path := writeKubeconfigs(t)
+
+	_, restConfig, _, err := newClient(KubeconfigOptions{
+		Path:              path,
+		Impersonate:       "team-a",
+		ImpersonateGroups: []string{"developers"},
+		ImpersonateUID:    "1234",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonate := restConfig.Impersonate
+	if impersonate.UserName != "team-a" || len(impersonate.Groups) != 1 || impersonate.Groups[0] != "developers" || impersonate.UID != "1234" {
+		t.Errorf("impersonation is not set: %v", impersonate)
+	}
+}
+
+func TestNewRestConfigWithAuth(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+	path := writeKubeconfigs(t)
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	if _, _, err := newRestConfig(KubeconfigOptions{Path: path, Auth: AuthKubeconfig}); err != nil {
+		t.Errorf("kubeconfig should be used: %v", err)
+	}
+	_, _, err := newRestConfig(KubeconfigOptions{Path: missing, Auth: AuthKubeconfig})
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("missing kubeconfig should be error: %v", err)
+	}
+	if _, _, err := newRestConfig(KubeconfigOptions{Path: missing, Auth: AuthAuto}); err != rest.ErrNotInCluster {
+		t.Errorf("in-cluster config should be used: %v", err)
+	}
+	if _, _, err := newRestConfig(KubeconfigOptions{Path: path, Auth: AuthInCluster}); err != rest.ErrNotInCluster {
+		t.Errorf("in-cluster config should be used: %v", err)
+	}
+	if _, _, err := newRestConfig(KubeconfigOptions{Path: path, Auth: "unknown"}); err == nil {
+		t.Error("invalid auth should be error")
+	}
+}
+
+func TestResolveContext(t *testing.T) {
+	path := writeKubeconfigs(t)
+
+	resolved, err := ResolveContext(KubeconfigOptions{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Name != "first" || resolved.Cluster != "first" || resolved.Server != "https://first.example.com" {
+		t.Errorf("current-context is not resolved: %v", resolved)
+	}
+
+	resolved, err = ResolveContext(KubeconfigOptions{Path: path, Context: "second", Server: "https://override.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Name != "second" || resolved.Cluster != "second" || resolved.Server != "https://override.example.com" {
+		t.Errorf("context is not resolved: %v", resolved)
+	}
+}
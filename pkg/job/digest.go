@@ -0,0 +1,412 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DigestContainer resolves the image of the target container to the digest.
+	DigestContainer = "container"
+	// DigestAll resolves the images of all containers and init containers to the digests.
+	DigestAll = "all"
+)
+
+// manifestMediaTypes are accepted manifest types. Index types come first, so the digest is the same as docker pull.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func validateResolveDigest(mode string) error {
+	switch mode {
+	case "", DigestContainer, DigestAll:
+		return nil
+	}
+	return fmt.Errorf("Invalid resolve-digest %q, please set 'container' or 'all'", mode)
+}
+
+// imageReference is an image name which is split into parts, like docker.io, library/alpine and 3.20.
+type imageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+func parseImageReference(image string) imageReference {
+	ref := imageReference{}
+	name, digest, _ := strings.Cut(normalizeImage(image), "@")
+	ref.Digest = digest
+	name, ref.Tag = splitTag(name)
+	if len(ref.Tag) == 0 {
+		ref.Tag = "latest"
+	}
+	ref.Registry, ref.Repository, _ = strings.Cut(name, "/")
+	return ref
+}
+
+// splitTag splits the tag from the image name. The port of the registry is not a tag.
+func splitTag(name string) (string, string) {
+	i := strings.LastIndex(name, ":")
+	if i < 0 || i < strings.LastIndex(name, "/") {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
+
+// pinnedImage replaces the tag of the image with the digest, like alpine@sha256:...
+func pinnedImage(image, digest string) string {
+	name, _, _ := strings.Cut(image, "@")
+	name, _ = splitTag(name)
+	return name + "@" + digest
+}
+
+// apiHost returns the host of the registry v2 API.
+func (r imageReference) apiHost() string {
+	if r.Registry == "docker.io" {
+		return "registry-1.docker.io"
+	}
+	return r.Registry
+}
+
+// dockerConfig is credentials in the docker config file.
+type dockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredsStore  string                `json:"credsStore"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+}
+
+type dockerAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// loadDockerConfig reads config.json in DOCKER_CONFIG or ~/.docker. If it does not exist, it returns empty config.
+func loadDockerConfig() (*dockerConfig, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if len(dir) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return &dockerConfig{}, nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return &dockerConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "Failed to parse the docker config")
+	}
+	return &config, nil
+}
+
+// credentials returns the username and the password of the registry.
+// If there are no credentials, it returns empty strings, and the registry is accessed anonymously.
+func (c *dockerConfig) credentials(registry string) (string, string, error) {
+	keys := []string{registry, "https://" + registry, "http://" + registry}
+	if registry == "docker.io" {
+		keys = []string{"https://index.docker.io/v1/", "index.docker.io", "docker.io"}
+	}
+	helper := c.CredsStore
+	if h, ok := c.CredHelpers[registry]; ok {
+		helper = h
+	}
+	if len(helper) > 0 {
+		return credentialHelper(helper, keys[0])
+	}
+	for _, key := range keys {
+		auth, ok := c.Auths[key]
+		if !ok {
+			continue
+		}
+		if len(auth.Auth) == 0 {
+			return auth.Username, auth.Password, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "Failed to decode the auth of %s", key)
+		}
+		username, password, _ := strings.Cut(string(decoded), ":")
+		return username, password, nil
+	}
+	return "", "", nil
+}
+
+// credentialHelper gets the credentials with docker-credential-<helper>.
+func credentialHelper(helper, server string) (string, string, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stdout.String()+stderr.String(), "credentials not found") {
+			return "", "", nil
+		}
+		return "", "", errors.Wrapf(err, "Failed to get credentials of %s from docker-credential-%s", server, helper)
+	}
+	var result struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return "", "", errors.Wrapf(err, "Failed to parse credentials from docker-credential-%s", helper)
+	}
+	return result.Username, result.Secret, nil
+}
+
+// registryClient resolves tags to digests with the registry v2 API.
+// It is shared by the jobs of a matrix, so they run the same images.
+type registryClient struct {
+	httpClient *http.Client
+	// If it is nil, the docker config file is read when credentials are needed.
+	config *dockerConfig
+
+	mu sync.Mutex
+	// Images which are pinned to the digests, keyed by the original image.
+	pinned map[string]string
+}
+
+// pin returns the image which is pinned to the digest. Each image is resolved only once.
+func (c *registryClient) pin(ctx context.Context, image string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pinned, ok := c.pinned[image]; ok {
+		return pinned, false, nil
+	}
+	digest, err := c.resolveDigest(ctx, image)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "Failed to resolve the digest of %s", image)
+	}
+	if c.pinned == nil {
+		c.pinned = map[string]string{}
+	}
+	c.pinned[image] = pinnedImage(image, digest)
+	return c.pinned[image], true, nil
+}
+
+func (c *registryClient) credentials(registry string) (string, string, error) {
+	if c.config == nil {
+		config, err := loadDockerConfig()
+		if err != nil {
+			return "", "", err
+		}
+		c.config = config
+	}
+	return c.config.credentials(registry)
+}
+
+// resolveDigest returns the digest of the manifest which the tag of the image points to.
+func (c *registryClient) resolveDigest(ctx context.Context, image string) (string, error) {
+	ref := parseImageReference(image)
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", ref.apiHost(), ref.Repository, ref.Tag)
+	resp, err := c.request(ctx, http.MethodHead, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		return digest, nil
+	}
+
+	// Some registries do not return the digest for HEAD, so the digest is calculated from the manifest.
+	resp, err = c.request(ctx, http.MethodGet, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		return digest, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// request sends the request to the registry. When the registry requires authentication, it retries with the credentials.
+func (c *registryClient) request(ctx context.Context, method, rawurl string, ref imageReference) (*http.Response, error) {
+	resp, err := c.send(ctx, method, rawurl, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		authorization, err := c.authorize(ctx, resp.Header.Get("WWW-Authenticate"), ref)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.send(ctx, method, rawurl, authorization)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Registry returned %s for %s:%s", resp.Status, ref.Repository, ref.Tag)
+	}
+	return resp, nil
+}
+
+func (c *registryClient) send(ctx context.Context, method, rawurl, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.httpClient.Do(req)
+}
+
+// authorize returns the Authorization header for the challenge in WWW-Authenticate.
+// For Bearer, a token is requested from the realm with the credentials.
+func (c *registryClient) authorize(ctx context.Context, challenge string, ref imageReference) (string, error) {
+	username, password, err := c.credentials(ref.Registry)
+	if err != nil {
+		return "", err
+	}
+	scheme, rest, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if len(username) == 0 {
+			return "", fmt.Errorf("Registry %s requires credentials", ref.Registry)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("Unsupported authentication %q of registry %s", challenge, ref.Registry)
+	}
+
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(rest, -1) {
+		params[match[1]] = match[2]
+	}
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil || len(params["realm"]) == 0 {
+		return "", fmt.Errorf("Invalid realm in %q of registry %s", challenge, ref.Registry)
+	}
+	query := tokenURL.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if len(scope) == 0 {
+		scope = "repository:" + ref.Repository + ":pull"
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if len(username) > 0 {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to get a token of registry %s: %s", ref.Registry, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", errors.Wrapf(err, "Failed to parse the token of registry %s", ref.Registry)
+	}
+	if len(token.Token) == 0 {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+func (j *Job) registryClient() *registryClient {
+	if j.registry == nil {
+		j.registry = &registryClient{httpClient: new(http.Client)}
+	}
+	return j.registry
+}
+
+// resolveDigests replaces the tags of the images in the job with the digests, and records the original images
+// in the annotation. The digests are resolved once, so retries run the same images.
+func (j *Job) resolveDigests(ctx context.Context, job *v1.Job) error {
+	podSpec := &job.Spec.Template.Spec
+	containers := []*corev1.Container{}
+	if j.ResolveDigest == DigestAll {
+		for i := range podSpec.InitContainers {
+			containers = append(containers, &podSpec.InitContainers[i])
+		}
+		for i := range podSpec.Containers {
+			containers = append(containers, &podSpec.Containers[i])
+		}
+	} else {
+		name, err := j.targetContainerName(job)
+		if err != nil {
+			return err
+		}
+		index, err := findContainerIndex(job, name)
+		if err != nil {
+			return err
+		}
+		containers = append(containers, &podSpec.Containers[index])
+	}
+
+	originals := []string{}
+	for _, container := range containers {
+		if len(parseImageReference(container.Image).Digest) > 0 {
+			continue
+		}
+		pinned, resolved, err := j.registryClient().pin(ctx, container.Image)
+		if err != nil {
+			return err
+		}
+		if resolved {
+			j.logger().Info("Resolved the image digest", "container", container.Name, "image", container.Image, "pinned", pinned)
+		}
+		originals = append(originals, container.Name+"="+container.Image)
+		container.Image = pinned
+	}
+	if len(originals) > 0 {
+		job.Annotations = mergeMap(job.Annotations, map[string]string{
+			OriginalImagesAnnotation: strings.Join(originals, ","),
+		})
+	}
+	return nil
+}
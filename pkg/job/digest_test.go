@@ -0,0 +1,229 @@
+package job
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	cases := []struct {
+		image    string
+		expected imageReference
+	}{
+		{"alpine", imageReference{Registry: "docker.io", Repository: "library/alpine", Tag: "latest"}},
+		{"h3poteto/kube-job:v1", imageReference{Registry: "docker.io", Repository: "h3poteto/kube-job", Tag: "v1"}},
+		{"localhost:5000/app", imageReference{Registry: "localhost:5000", Repository: "app", Tag: "latest"}},
+		{"gcr.io/project/app:1.0@sha256:abc", imageReference{Registry: "gcr.io", Repository: "project/app", Tag: "1.0", Digest: "sha256:abc"}},
+	}
+	for _, c := range cases {
+		if ref := parseImageReference(c.image); ref != c.expected {
+			t.Errorf("%s is parsed to %v, expected %v", c.image, ref, c.expected)
+		}
+	}
+}
+
+func TestPinnedImage(t *testing.T) {
+	cases := map[string]string{
+		"alpine":                      "alpine@sha256:abc",
+		"alpine:3.20":                 "alpine@sha256:abc",
+		"localhost:5000/app:v1":       "localhost:5000/app@sha256:abc",
+		"gcr.io/project/app@sha256:x": "gcr.io/project/app@sha256:abc",
+	}
+	for image, expected := range cases {
+		if pinned := pinnedImage(image, "sha256:abc"); pinned != expected {
+			t.Errorf("%s is pinned to %s, expected %s", image, pinned, expected)
+		}
+	}
+}
+
+func TestDockerConfigCredentials(t *testing.T) {
+	config := &dockerConfig{
+		Auths: map[string]dockerAuth{
+			"https://index.docker.io/v1/": {Auth: base64.StdEncoding.EncodeToString([]byte("hub:secret"))},
+			"registry.example.com":        {Username: "user", Password: "password"},
+		},
+	}
+	if username, password, err := config.credentials("docker.io"); err != nil || username != "hub" || password != "secret" {
+		t.Errorf("credentials of Docker Hub are not found: %s, %s, %v", username, password, err)
+	}
+	if username, password, err := config.credentials("registry.example.com"); err != nil || username != "user" || password != "password" {
+		t.Errorf("credentials of the registry are not found: %s, %s, %v", username, password, err)
+	}
+	if username, _, err := config.credentials("gcr.io"); err != nil || len(username) > 0 {
+		t.Errorf("unknown registry should be anonymous: %s, %v", username, err)
+	}
+}
+
+func newRegistryServer(t *testing.T, digest string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token": "registry-token"}`)
+		case "/v2/team/app/manifests/v1":
+			if r.Header.Get("Authorization") != "Bearer registry-token" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", digest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunJobWithResolveDigest(t *testing.T) {
+	digest := "sha256:0123456789abcdef"
+	server := newRegistryServer(t, digest)
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{
+		CurrentJob:    currentJob,
+		Container:     "alpine",
+		Image:         host + "/team/app:v1",
+		ResolveDigest: DigestContainer,
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: mockedJob{},
+			},
+		},
+		registry: &registryClient{
+			httpClient: server.Client(),
+			config: &dockerConfig{
+				Auths: map[string]dockerAuth{host: {Username: "user", Password: "password"}},
+			},
+		},
+	}
+
+	j, err := job.RunJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := j.Spec.Template.Spec.Containers[0].Image; image != host+"/team/app@"+digest {
+		t.Errorf("image is not pinned: %s", image)
+	}
+	if original := j.Annotations[OriginalImagesAnnotation]; original != "alpine="+host+"/team/app:v1" {
+		t.Errorf("original image is not recorded: %s", original)
+	}
+
+	// Retries use the resolved digest without accessing the registry.
+	server.Close()
+	j, err = job.RunJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := j.Spec.Template.Spec.Containers[0].Image; image != host+"/team/app@"+digest {
+		t.Errorf("image is not pinned in the retry: %s", image)
+	}
+}
+
+func TestRunJobWithUnresolvableDigest(t *testing.T) {
+	server := newRegistryServer(t, "sha256:0123456789abcdef")
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{
+		CurrentJob:    currentJob,
+		Container:     "alpine",
+		Image:         host + "/team/missing:v1",
+		ResolveDigest: DigestAll,
+		registry:      &registryClient{httpClient: server.Client(), config: &dockerConfig{}},
+	}
+	if _, err := job.RunJob(context.Background()); err == nil || !strings.Contains(err.Error(), "Failed to resolve the digest") {
+		t.Errorf("unresolvable image should be error: %v", err)
+	}
+}
+
+func TestMatrixSharesResolvedDigests(t *testing.T) {
+	digest := "sha256:0123456789abcdef"
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/team/app/manifests/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Header().Set("Docker-Content-Digest", digest)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{
+		CurrentJob:    currentJob,
+		Container:     "alpine",
+		Image:         host + "/team/app:v1",
+		ResolveDigest: DigestContainer,
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: mockedJob{},
+			},
+		},
+		registry: &registryClient{httpClient: server.Client(), config: &dockerConfig{}},
+	}
+
+	children := []*Job{
+		job.withParameters(Parameters{"tenant": "a"}, io.Discard),
+		job.withParameters(Parameters{"tenant": "b"}, io.Discard),
+	}
+	if children[0].registry != children[1].registry {
+		t.Fatal("jobs in the matrix should share the registry client")
+	}
+	var wg sync.WaitGroup
+	images := make([]string, len(children))
+	errs := make([]error, len(children))
+	for i, child := range children {
+		wg.Add(1)
+		go func(i int, child *Job) {
+			defer wg.Done()
+			j, err := child.RunJob(context.Background())
+			errs[i] = err
+			if err == nil {
+				images[i] = j.Spec.Template.Spec.Containers[0].Image
+			}
+		}(i, child)
+	}
+	wg.Wait()
+	for i := range children {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if images[i] != host+"/team/app@"+digest {
+			t.Errorf("image is not pinned: %s", images[i])
+		}
+	}
+	if requests != 1 {
+		t.Errorf("digest should be resolved once for all jobs in the matrix: %d requests", requests)
+	}
+}
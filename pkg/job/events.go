@@ -0,0 +1,176 @@
+package job
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// EventType is a type of the lifecycle event.
+type EventType string
+
+const (
+	// EventJobCreated is emitted when the job is created.
+	EventJobCreated EventType = "JobCreated"
+	// EventPodScheduled is emitted when a pod of the job is scheduled to a node. Reason is the node name.
+	EventPodScheduled EventType = "PodScheduled"
+	// EventContainerStarted is emitted when a container in the pod is started.
+	EventContainerStarted EventType = "ContainerStarted"
+	// EventContainerTerminated is emitted when a container in the pod is terminated.
+	EventContainerTerminated EventType = "ContainerTerminated"
+	// EventRetry is emitted when the failed job is retried. Reason is the retry reason.
+	EventRetry EventType = "Retry"
+	// EventJobFinished is emitted when the job is finished. Err is the result of the job.
+	EventJobFinished EventType = "JobFinished"
+	// EventCleanupDone is emitted when the job and the pods are removed. Err is set if the removal is failed.
+	EventCleanupDone EventType = "CleanupDone"
+)
+
+// Event is a lifecycle event of the job.
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	Namespace string
+	// Name of the job.
+	Job string
+	// Name of the pod, only for pod and container events.
+	Pod string
+	// Name of the container, only for container events.
+	Container string
+	// Exit code of the container, only for EventContainerTerminated.
+	ExitCode int32
+	Reason   string
+	// Number of the failed attempt, only for EventRetry.
+	Attempt int
+	// Wait before the next attempt, only for EventRetry.
+	Backoff time.Duration
+	Err     error
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("%s job=%s", e.Type, e.Job)
+	if len(e.Pod) > 0 {
+		s += " pod=" + e.Pod
+	}
+	if len(e.Container) > 0 {
+		s += " container=" + e.Container
+	}
+	if e.Type == EventContainerTerminated {
+		s += fmt.Sprintf(" exitCode=%d", e.ExitCode)
+	}
+	if e.Attempt > 0 {
+		s += fmt.Sprintf(" attempt=%d", e.Attempt)
+	}
+	if e.Backoff > 0 {
+		s += " backoff=" + e.Backoff.String()
+	}
+	if len(e.Reason) > 0 {
+		s += " reason=" + e.Reason
+	}
+	if e.Err != nil {
+		s += fmt.Sprintf(" error=%q", e.Err.Error())
+	}
+	return s
+}
+
+// EventHandler receives lifecycle events of the job.
+// Events of parallel jobs, like RunMatrix and Workflow, are sent at the same time,
+// so the handler must be safe for concurrent use.
+type EventHandler interface {
+	HandleEvent(Event)
+}
+
+// EventHandlerFunc is an adapter to use a function as EventHandler.
+type EventHandlerFunc func(Event)
+
+// HandleEvent calls f(e).
+func (f EventHandlerFunc) HandleEvent(e Event) {
+	f(e)
+}
+
+// emitEvent fills the job and the time of the event, and sends it to the handler if it is set.
+func emitEvent(handler EventHandler, job *v1.Job, e Event) {
+	if handler == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if job != nil {
+		e.Namespace = job.Namespace
+		e.Job = job.Name
+	}
+	handler.HandleEvent(e)
+}
+
+// podTracker emits pod and container events by comparing states of the pods which are observed.
+// Each event is emitted only once.
+type podTracker struct {
+	handler EventHandler
+	job     *v1.Job
+	seen    map[string]bool
+}
+
+func newPodTracker(handler EventHandler, job *v1.Job) *podTracker {
+	return &podTracker{
+		handler: handler,
+		job:     job,
+		seen:    map[string]bool{},
+	}
+}
+
+func (t *podTracker) emit(key string, e Event) {
+	if t.seen[key] {
+		return
+	}
+	t.seen[key] = true
+	emitEvent(t.handler, t.job, e)
+}
+
+func (t *podTracker) observe(pods []corev1.Pod) {
+	if t.handler == nil {
+		return
+	}
+	for _, pod := range pods {
+		if podIsScheduled(pod) {
+			t.emit(pod.Name+"/scheduled", Event{Type: EventPodScheduled, Pod: pod.Name, Reason: pod.Spec.NodeName})
+		}
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if status.RestartCount > 0 && status.LastTerminationState.Terminated != nil {
+				t.observeContainer(pod.Name, status.Name, status.RestartCount-1, status.LastTerminationState)
+			}
+			t.observeContainer(pod.Name, status.Name, status.RestartCount, status.State)
+		}
+	}
+}
+
+// observeContainer emits events of the container. Started is emitted before terminated,
+// even if the container is terminated before the running state is observed.
+func (t *podTracker) observeContainer(podName, containerName string, restartCount int32, state corev1.ContainerState) {
+	if state.Running == nil && state.Terminated == nil {
+		return
+	}
+	key := fmt.Sprintf("%s/%s/%d", podName, containerName, restartCount)
+	t.emit(key+"/started", Event{Type: EventContainerStarted, Pod: podName, Container: containerName})
+	if state.Terminated != nil {
+		t.emit(key+"/terminated", Event{
+			Type:      EventContainerTerminated,
+			Pod:       podName,
+			Container: containerName,
+			ExitCode:  state.Terminated.ExitCode,
+			Reason:    state.Terminated.Reason,
+		})
+	}
+}
+
+func podIsScheduled(pod corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
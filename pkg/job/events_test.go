@@ -0,0 +1,115 @@
+package job
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type recordedEvents struct {
+	events []Event
+}
+
+func (r *recordedEvents) HandleEvent(e Event) {
+	r.events = append(r.events, e)
+}
+
+func (r *recordedEvents) types() []EventType {
+	types := []EventType{}
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestPodTrackerObserve(t *testing.T) {
+	recorder := &recordedEvents{}
+	job := &v1.Job{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+	tracker := newPodTracker(recorder, job)
+
+	pending := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-abcde"},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "alpine", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}},
+			},
+		},
+	}
+	tracker.observe([]corev1.Pod{pending})
+	tracker.observe([]corev1.Pod{pending})
+	if !reflect.DeepEqual(recorder.types(), []EventType{EventPodScheduled}) {
+		t.Errorf("unexpected events: %v", recorder.types())
+	}
+	if recorder.events[0].Job != "example" || recorder.events[0].Reason != "node1" {
+		t.Errorf("unexpected event: %v", recorder.events[0])
+	}
+
+	// The container is terminated before the running state is observed.
+	terminated := *pending.DeepCopy()
+	terminated.Status.ContainerStatuses[0].State = corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"},
+	}
+	tracker.observe([]corev1.Pod{terminated})
+	tracker.observe([]corev1.Pod{terminated})
+	expected := []EventType{EventPodScheduled, EventContainerStarted, EventContainerTerminated}
+	if !reflect.DeepEqual(recorder.types(), expected) {
+		t.Errorf("unexpected events: %v", recorder.types())
+	}
+	if recorder.events[2].ExitCode != 1 || recorder.events[2].Container != "alpine" {
+		t.Errorf("unexpected event: %v", recorder.events[2])
+	}
+
+	// The container is restarted.
+	restarted := *terminated.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 1
+	restarted.Status.ContainerStatuses[0].LastTerminationState = terminated.Status.ContainerStatuses[0].State
+	restarted.Status.ContainerStatuses[0].State = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	tracker.observe([]corev1.Pod{restarted})
+	expected = append(expected, EventContainerStarted)
+	if !reflect.DeepEqual(recorder.types(), expected) {
+		t.Errorf("unexpected events: %v", recorder.types())
+	}
+}
+
+func TestRunJobAndCleanupEmitEvents(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	recorder := &recordedEvents{}
+	job := &Job{
+		CurrentJob: currentJob,
+		Container:  "alpine",
+		Events:     recorder,
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: mockedJob{},
+			},
+			mockedCore: mockedCoreV1{
+				mockedPod: mockedPod{
+					jobName: currentJob.Name,
+				},
+			},
+		},
+	}
+	if _, err := job.RunJob(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if err := job.Cleanup(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(recorder.types(), []EventType{EventJobCreated, EventCleanupDone}) {
+		t.Errorf("unexpected events: %v", recorder.types())
+	}
+	if recorder.events[0].Job != currentJob.Name {
+		t.Errorf("unexpected job name: %s", recorder.events[0].Job)
+	}
+}
@@ -0,0 +1,155 @@
+package job
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// maxObjectSize is the limit of the data in a ConfigMap or a Secret.
+const maxObjectSize = 1024 * 1024
+
+const (
+	filesVolume       = "kube-job-files"
+	secretFilesVolume = "kube-job-secret-files"
+)
+
+// MountedFile is a file which is mounted into the target container.
+// Files are stored in a ConfigMap or a Secret which is created with the job, and removed with the job.
+type MountedFile struct {
+	// Absolute path of the file in the container.
+	MountPath string
+	// Content of the file.
+	Data []byte
+	// If you set true, the file is stored in a Secret instead of a ConfigMap.
+	Secret bool
+}
+
+// ParseMountedFile reads the local file in LOCAL:MOUNT_PATH.
+func ParseMountedFile(value string, secret bool) (MountedFile, error) {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 {
+		return MountedFile{}, fmt.Errorf("Invalid file %q, it must be LOCAL:MOUNT_PATH", value)
+	}
+	data, err := os.ReadFile(value[:i])
+	if err != nil {
+		return MountedFile{}, err
+	}
+	return MountedFile{MountPath: value[i+1:], Data: data, Secret: secret}, nil
+}
+
+func validateFiles(files []MountedFile) error {
+	mountPaths := map[string]bool{}
+	sizes := map[bool]int{}
+	for _, file := range files {
+		if !path.IsAbs(file.MountPath) {
+			return fmt.Errorf("Mount path %q of the file must be absolute", file.MountPath)
+		}
+		if mountPaths[file.MountPath] {
+			return fmt.Errorf("Mount path %s is duplicated", file.MountPath)
+		}
+		mountPaths[file.MountPath] = true
+		sizes[file.Secret] += len(file.Data)
+	}
+	for secret, size := range sizes {
+		if size > maxObjectSize {
+			kind := "ConfigMap"
+			if secret {
+				kind = "Secret"
+			}
+			return fmt.Errorf("Files are %d bytes, which exceed the limit of a %s", size, kind)
+		}
+	}
+	return nil
+}
+
+// filesName returns the name of the ConfigMap or the Secret which has the files of the job.
+func filesName(jobName string, secret bool) string {
+	if secret {
+		return jobName + "-secret-files"
+	}
+	return jobName + "-files"
+}
+
+// filesData returns the files which are stored in the ConfigMap or the Secret, keyed by file-N.
+func (j *Job) filesData(secret bool) map[string][]byte {
+	data := map[string][]byte{}
+	for _, file := range j.Files {
+		if file.Secret == secret {
+			data[fmt.Sprintf("file-%d", len(data))] = file.Data
+		}
+	}
+	return data
+}
+
+// applyFiles adds the volumes of the files to the job, and mounts them into the container.
+func (j *Job) applyFiles(job *v1.Job, index int) {
+	podSpec := &job.Spec.Template.Spec
+	container := &podSpec.Containers[index]
+	keys := map[bool]int{}
+	for _, file := range j.Files {
+		volume := filesVolume
+		if file.Secret {
+			volume = secretFilesVolume
+		}
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volume,
+			MountPath: file.MountPath,
+			SubPath:   fmt.Sprintf("file-%d", keys[file.Secret]),
+			ReadOnly:  true,
+		})
+		keys[file.Secret]++
+	}
+	if keys[false] > 0 {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: filesVolume,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: filesName(job.Name, false)},
+				},
+			},
+		})
+	}
+	if keys[true] > 0 {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: secretFilesVolume,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: filesName(job.Name, true)},
+			},
+		})
+	}
+}
+
+// filesConfigMap returns the ConfigMap which has the files of the job. If there are no files, it returns nil.
+func (j *Job) filesConfigMap(job *v1.Job) *corev1.ConfigMap {
+	data := j.filesData(false)
+	if len(data) == 0 {
+		return nil
+	}
+	configMap := &corev1.ConfigMap{ObjectMeta: j.objectMeta(job, filesName(job.Name, false))}
+	for key, value := range data {
+		if utf8.Valid(value) {
+			configMap.Data = mergeMap(configMap.Data, map[string]string{key: string(value)})
+		} else {
+			if configMap.BinaryData == nil {
+				configMap.BinaryData = map[string][]byte{}
+			}
+			configMap.BinaryData[key] = value
+		}
+	}
+	return configMap
+}
+
+// filesSecret returns the Secret which has the secret files of the job. If there are no secret files, it returns nil.
+func (j *Job) filesSecret(job *v1.Job) *corev1.Secret {
+	data := j.filesData(true)
+	if len(data) == 0 {
+		return nil
+	}
+	return &corev1.Secret{ObjectMeta: j.objectMeta(job, filesName(job.Name, true)), Data: data}
+}
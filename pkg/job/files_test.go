@@ -0,0 +1,138 @@
+package job
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestParseMountedFile(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "script.sql")
+	if err := os.WriteFile(local, []byte("SELECT 1;"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := ParseMountedFile(local+":/work/script.sql", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.MountPath != "/work/script.sql" || string(file.Data) != "SELECT 1;" || !file.Secret {
+		t.Errorf("file is not parsed: %v", file)
+	}
+	if _, err := ParseMountedFile(local, false); err == nil {
+		t.Error("file without mount path should be error")
+	}
+}
+
+func TestValidateFiles(t *testing.T) {
+	if err := validateFiles([]MountedFile{{MountPath: "work/script.sql"}}); err == nil {
+		t.Error("relative mount path should be error")
+	}
+	if err := validateFiles([]MountedFile{{MountPath: "/work/a"}, {MountPath: "/work/a", Secret: true}}); err == nil {
+		t.Error("duplicated mount path should be error")
+	}
+	if err := validateFiles([]MountedFile{{MountPath: "/work/a", Data: make([]byte, maxObjectSize+1)}}); err == nil {
+		t.Error("large files should be error")
+	}
+	if err := validateFiles([]MountedFile{{MountPath: "/work/a", Data: make([]byte, maxObjectSize)}, {MountPath: "/work/b", Data: []byte("b"), Secret: true}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunJobWithFiles(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewClientset()
+	job := &Job{
+		CurrentJob: currentJob,
+		Container:  "alpine",
+		Files: []MountedFile{
+			{MountPath: "/work/script.sql", Data: []byte("SELECT 1;")},
+			{MountPath: "/work/binary", Data: []byte{0xff, 0xfe}},
+			{MountPath: "/work/password", Data: []byte("secret"), Secret: true},
+		},
+		client: client,
+	}
+	ctx := context.Background()
+
+	j, err := job.RunJob(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounts := j.Spec.Template.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 3 || mounts[0].SubPath != "file-0" || mounts[1].SubPath != "file-1" || mounts[2].Name != secretFilesVolume || mounts[2].SubPath != "file-0" {
+		t.Errorf("files are not mounted: %v", mounts)
+	}
+	if volumes := j.Spec.Template.Spec.Volumes; len(volumes) != 2 || volumes[0].ConfigMap.Name != j.Name+"-files" || volumes[1].Secret.SecretName != j.Name+"-secret-files" {
+		t.Errorf("volumes are not added: %v", volumes)
+	}
+
+	configMap, err := client.CoreV1().ConfigMaps(j.Namespace).Get(ctx, j.Name+"-files", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configMap.Data["file-0"] != "SELECT 1;" || len(configMap.BinaryData["file-1"]) != 2 {
+		t.Errorf("ConfigMap does not have the files: %v", configMap)
+	}
+	if len(configMap.OwnerReferences) != 1 || configMap.OwnerReferences[0].Name != j.Name {
+		t.Errorf("ConfigMap is not owned by the job: %v", configMap.OwnerReferences)
+	}
+	secret, err := client.CoreV1().Secrets(j.Namespace).Get(ctx, j.Name+"-secret-files", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["file-0"]) != "secret" || len(secret.OwnerReferences) != 1 {
+		t.Errorf("Secret does not have the file: %v", secret)
+	}
+
+	if err := job.Cleanup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().ConfigMaps(j.Namespace).Get(ctx, j.Name+"-files", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("ConfigMap is not removed: %v", err)
+	}
+	if _, err := client.CoreV1().Secrets(j.Namespace).Get(ctx, j.Name+"-secret-files", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("Secret is not removed: %v", err)
+	}
+}
+
+func TestRunJobRemovesFilesWhenSecretIsNotCreated(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewClientset()
+	client.PrependReactor("create", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("secrets is forbidden")
+	})
+	job := &Job{
+		CurrentJob: currentJob,
+		Container:  "alpine",
+		Files: []MountedFile{
+			{MountPath: "/work/script.sql", Data: []byte("SELECT 1;")},
+			{MountPath: "/work/password", Data: []byte("secret"), Secret: true},
+		},
+		client: client,
+	}
+	ctx := context.Background()
+
+	if _, err := job.RunJob(ctx); err == nil {
+		t.Fatal("job should not be run without the Secret")
+	}
+	configMaps, err := client.CoreV1().ConfigMaps(currentJob.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configMaps.Items) != 0 {
+		t.Errorf("ConfigMap is not removed: %v", configMaps.Items)
+	}
+}
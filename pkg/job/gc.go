@@ -0,0 +1,94 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GCOptions describes which jobs are removed by garbage collection.
+// Running jobs are never removed.
+type GCOptions struct {
+	// Namespace of the jobs. If you set empty, jobs in all namespaces are collected.
+	Namespace string
+	// Remove jobs which are created before this duration.
+	OlderThan time.Duration
+	// Succeeded or Failed. If you set empty, both are removed.
+	Status string
+	// Number of the newest finished jobs which are kept for each template.
+	KeepLast int
+	// If you set true, jobs are not removed, and only returned.
+	DryRun bool
+	// Logger to write the removed jobs. If you set nil, nothing is written.
+	Logger *slog.Logger
+}
+
+// CollectGarbage removes finished jobs and the pods which are created by kube-job.
+// It returns the removed jobs.
+func CollectGarbage(ctx context.Context, client kubernetes.Interface, options GCOptions) ([]JobSummary, error) {
+	if options.Status == StatusRunning {
+		return nil, fmt.Errorf("Running jobs can not be removed, please set 'Succeeded' or 'Failed'")
+	}
+	summaries, err := ListJobs(ctx, client, ListOptions{
+		Namespace: options.Namespace,
+		Status:    options.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	garbage := selectGarbage(summaries, options, time.Now())
+	if options.DryRun {
+		return garbage, nil
+	}
+
+	logger := loggerOrDiscard(options.Logger)
+	propagation := metav1.DeletePropagationBackground
+	removed := []JobSummary{}
+	for _, s := range garbage {
+		logger.Info("Removing the job", "namespace", s.Namespace, "job", s.Name)
+		err := client.BatchV1().Jobs(s.Namespace).Delete(ctx, s.Name, metav1.DeleteOptions{
+			PropagationPolicy: &propagation,
+		})
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, s)
+	}
+	return removed, nil
+}
+
+// selectGarbage selects finished jobs which are older than the options, except the newest jobs of each template.
+func selectGarbage(summaries []JobSummary, options GCOptions, now time.Time) []JobSummary {
+	templates := map[string][]JobSummary{}
+	keys := []string{}
+	for _, s := range summaries {
+		if s.Status == StatusRunning {
+			continue
+		}
+		key := s.Namespace + "/" + s.Template
+		if _, ok := templates[key]; !ok {
+			keys = append(keys, key)
+		}
+		templates[key] = append(templates[key], s)
+	}
+
+	garbage := []JobSummary{}
+	for _, key := range keys {
+		jobs := templates[key]
+		sort.SliceStable(jobs, func(i, j int) bool {
+			return jobs[i].Created.After(jobs[j].Created)
+		})
+		for i, s := range jobs {
+			if i < options.KeepLast || s.Age(now) < options.OlderThan {
+				continue
+			}
+			garbage = append(garbage, s)
+		}
+	}
+	return garbage
+}
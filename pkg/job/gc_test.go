@@ -0,0 +1,38 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectGarbage(t *testing.T) {
+	now := time.Now()
+	summaries := []JobSummary{
+		{Namespace: "default", Name: "migrate-1", Template: "migrate", Status: StatusSucceeded, Created: now.Add(-72 * time.Hour)},
+		{Namespace: "default", Name: "migrate-2", Template: "migrate", Status: StatusFailed, Created: now.Add(-48 * time.Hour)},
+		{Namespace: "default", Name: "migrate-3", Template: "migrate", Status: StatusSucceeded, Created: now.Add(-30 * time.Hour)},
+		{Namespace: "default", Name: "migrate-4", Template: "migrate", Status: StatusSucceeded, Created: now.Add(-1 * time.Hour)},
+		{Namespace: "default", Name: "migrate-5", Template: "migrate", Status: StatusRunning, Created: now.Add(-96 * time.Hour)},
+		{Namespace: "default", Name: "seed-1", Template: "seed", Status: StatusSucceeded, Created: now.Add(-72 * time.Hour)},
+	}
+
+	garbage := selectGarbage(summaries, GCOptions{OlderThan: 24 * time.Hour}, now)
+	names := []string{}
+	for _, s := range garbage {
+		names = append(names, s.Name)
+	}
+	expected := []string{"migrate-3", "migrate-2", "migrate-1", "seed-1"}
+	if len(names) != len(expected) {
+		t.Fatalf("garbage does not match: %v", names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("garbage does not match: %v", names)
+		}
+	}
+
+	garbage = selectGarbage(summaries, GCOptions{OlderThan: 24 * time.Hour, KeepLast: 2}, now)
+	if len(garbage) != 2 || garbage[0].Name != "migrate-2" || garbage[1].Name != "migrate-1" {
+		t.Errorf("newest jobs should be kept for each template: %v", garbage)
+	}
+}
@@ -0,0 +1,129 @@
+package job
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DuplicateAttach attaches to the running job which has the same idempotency key.
+	// If there is no running job, a new job is run.
+	DuplicateAttach = "attach"
+	// DuplicateReuse returns the result of the finished job which has the same idempotency key.
+	// If the job is still running, it attaches to the job.
+	DuplicateReuse = "reuse"
+	// DuplicateFail returns error if a job which has the same idempotency key exists.
+	DuplicateFail = "fail"
+	// DuplicateRun always runs a new job.
+	DuplicateRun = "run"
+)
+
+// hashIdempotencyKey returns a hash of the key which can be used as a label value.
+func hashIdempotencyKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:32]
+}
+
+// idempotentName returns the name of the job which has the hash of the key instead of a random string.
+func idempotentName(name, key string) string {
+	// label must be no more than 63 characters long
+	length := int(math.Min(float64(62-len(name)), float64(16)))
+	return fmt.Sprintf("%s-%s", name, hashIdempotencyKey(key)[:length])
+}
+
+func validateOnDuplicate(onDuplicate string) error {
+	switch onDuplicate {
+	case "", DuplicateAttach, DuplicateReuse, DuplicateFail, DuplicateRun:
+		return nil
+	}
+	return fmt.Errorf("Invalid on-duplicate %q, please set 'attach', 'reuse', 'fail' or 'run'", onDuplicate)
+}
+
+// findDuplicateJob looks up jobs which have the same idempotency key.
+// It returns the job to be used instead of creating a new job, or nil if a new job should be created.
+func (j *Job) findDuplicateJob(ctx context.Context) (*v1.Job, error) {
+	if len(j.IdempotencyKey) == 0 || j.OnDuplicate == DuplicateRun {
+		return nil, nil
+	}
+	listOptions := metav1.ListOptions{
+		LabelSelector: IdempotencyKeyLabel + "=" + hashIdempotencyKey(j.IdempotencyKey),
+	}
+	jobList, err := j.client.BatchV1().Jobs(j.CurrentJob.Namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	return selectDuplicateJob(jobList.Items, j.OnDuplicate)
+}
+
+// existingDuplicateJob returns the job which already has the name of the current job, when creating the job conflicts.
+// It returns nil if a new job should be created with another name.
+func (j *Job) existingDuplicateJob(ctx context.Context) (*v1.Job, error) {
+	existing, err := j.client.BatchV1().Jobs(j.CurrentJob.Namespace).Get(ctx, j.CurrentJob.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return selectDuplicateJob([]v1.Job{*existing}, j.OnDuplicate)
+}
+
+// selectDuplicateJob selects a job from the duplicates according to onDuplicate.
+// Running jobs are preferred to finished jobs, and newer jobs are preferred to older jobs.
+func selectDuplicateJob(jobs []v1.Job, onDuplicate string) (*v1.Job, error) {
+	var running, finished *v1.Job
+	for i := range jobs {
+		job := &jobs[i]
+		if jobIsFinished(job) {
+			if finished == nil || finished.CreationTimestamp.Before(&job.CreationTimestamp) {
+				finished = job
+			}
+		} else if running == nil || running.CreationTimestamp.Before(&job.CreationTimestamp) {
+			running = job
+		}
+	}
+	switch onDuplicate {
+	case DuplicateFail:
+		if running != nil {
+			return nil, fmt.Errorf("Job %s which has the same idempotency key is running", running.Name)
+		}
+		if finished != nil {
+			return nil, fmt.Errorf("Job %s which has the same idempotency key is already finished", finished.Name)
+		}
+	case DuplicateReuse:
+		if running != nil {
+			return running, nil
+		}
+		return finished, nil
+	default:
+		return running, nil
+	}
+	return nil, nil
+}
+
+// jobIsFinished checks whether the job has Complete or Failed condition.
+func jobIsFinished(job *v1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == v1.JobComplete || condition.Type == v1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// attachDuplicateJob records the duplicate to wait it instead of creating a job.
+// CurrentJob is kept as the template, and only the name is replaced with the duplicate to refer to it.
+// The duplicate is not cleaned up, because it belongs to another run.
+func (j *Job) attachDuplicateJob(duplicate *v1.Job) {
+	if jobIsFinished(duplicate) {
+		j.logger().Warn("Job which has the same idempotency key is already finished, so reuse the result", "job", duplicate.Name)
+	} else {
+		j.logger().Warn("Job which has the same idempotency key is running, so attach to it", "job", duplicate.Name)
+	}
+	j.attached = duplicate.DeepCopy()
+	j.CurrentJob.SetName(duplicate.Name)
+}
@@ -0,0 +1,205 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/batch/v1"
+	v1core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHashIdempotencyKey(t *testing.T) {
+	hash := hashIdempotencyKey("migrate-20260101")
+	if len(hash) != 32 {
+		t.Errorf("hash length is not correct: %s", hash)
+	}
+	if hash != hashIdempotencyKey("migrate-20260101") {
+		t.Error("hash should be stable")
+	}
+	if hash == hashIdempotencyKey("migrate-20260102") {
+		t.Error("hash should be different for another key")
+	}
+}
+
+func TestSelectDuplicateJob(t *testing.T) {
+	now := time.Now()
+	running := v1.Job{}
+	running.Name = "running"
+	running.CreationTimestamp = metav1.NewTime(now)
+	oldFinished := v1.Job{
+		Status: v1.JobStatus{
+			Conditions: []v1.JobCondition{
+				{
+					Type:   v1.JobFailed,
+					Status: v1core.ConditionTrue,
+				},
+			},
+		},
+	}
+	oldFinished.Name = "old-finished"
+	oldFinished.CreationTimestamp = metav1.NewTime(now.Add(-2 * time.Hour))
+	finished := v1.Job{
+		Status: v1.JobStatus{
+			Conditions: []v1.JobCondition{
+				{
+					Type:   v1.JobComplete,
+					Status: v1core.ConditionTrue,
+				},
+			},
+		},
+	}
+	finished.Name = "finished"
+	finished.CreationTimestamp = metav1.NewTime(now.Add(-1 * time.Hour))
+
+	job, err := selectDuplicateJob([]v1.Job{oldFinished, finished, running}, DuplicateAttach)
+	if err != nil || job == nil || job.Name != "running" {
+		t.Errorf("attach should select the running job: %v, %v", job, err)
+	}
+	job, err = selectDuplicateJob([]v1.Job{oldFinished, finished}, DuplicateAttach)
+	if err != nil || job != nil {
+		t.Errorf("attach should not select finished jobs: %v, %v", job, err)
+	}
+	job, err = selectDuplicateJob([]v1.Job{oldFinished, finished}, DuplicateReuse)
+	if err != nil || job == nil || job.Name != "finished" {
+		t.Errorf("reuse should select the newest finished job: %v, %v", job, err)
+	}
+	job, err = selectDuplicateJob([]v1.Job{finished, running}, DuplicateReuse)
+	if err != nil || job == nil || job.Name != "running" {
+		t.Errorf("reuse should prefer the running job: %v, %v", job, err)
+	}
+	if _, err = selectDuplicateJob([]v1.Job{finished}, DuplicateFail); err == nil {
+		t.Error("fail should return error when a duplicate exists")
+	}
+	job, err = selectDuplicateJob([]v1.Job{}, DuplicateFail)
+	if err != nil || job != nil {
+		t.Errorf("fail should not return error without duplicates: %v, %v", job, err)
+	}
+}
+
+func TestRunJobWithDuplicate(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	duplicate := currentJob.DeepCopy()
+	duplicate.Name = "duplicate"
+	duplicate.UID = "duplicate-uid"
+	duplicate.ResourceVersion = "10"
+	jobMock := mockedJob{
+		job: currentJob,
+		jobList: &v1.JobList{
+			Items: []v1.Job{*duplicate},
+		},
+	}
+	job := &Job{
+		CurrentJob:     currentJob,
+		Container:      "alpine",
+		IdempotencyKey: "migrate",
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: jobMock,
+			},
+		},
+	}
+
+	j, err := job.RunJob(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+	if j.Name != "duplicate" || job.CurrentJob.Name != "duplicate" {
+		t.Errorf("job should attach to the duplicate: %v", j)
+	}
+	if len(job.CurrentJob.UID) > 0 || len(job.CurrentJob.ResourceVersion) > 0 {
+		t.Errorf("current job should be kept as the template: %v", job.CurrentJob.ObjectMeta)
+	}
+
+	// Retries do not attach to the duplicate again.
+	job.CurrentJob.SetName(generateRandomName(currentJob.Name))
+	job.retried = true
+	j, err = job.RunJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.attached != nil || j.Name == "duplicate" {
+		t.Errorf("retry should run a new job: %v", j.Name)
+	}
+}
+
+func TestMatrixIdempotencyKey(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{CurrentJob: currentJob, IdempotencyKey: "migrate"}
+	a := job.withParameters(Parameters{"tenant": "a"}, nil)
+	b := job.withParameters(Parameters{"tenant": "b"}, nil)
+	if a.IdempotencyKey == b.IdempotencyKey || a.IdempotencyKey != "migrate/tenant=a" {
+		t.Errorf("each parameter set should have its own idempotency key: %s, %s", a.IdempotencyKey, b.IdempotencyKey)
+	}
+}
+
+func TestRunJobWithConflictingName(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// Another run has just created the job, and it is not labeled yet.
+	running := currentJob.DeepCopy()
+	running.Name = idempotentName(currentJob.Name, "migrate")
+	client := fake.NewClientset(running)
+	job := &Job{
+		CurrentJob:     currentJob.DeepCopy(),
+		Container:      "alpine",
+		IdempotencyKey: "migrate",
+		SecretEnv:      map[string]string{"TOKEN": "secret"},
+		client:         client,
+	}
+
+	j, err := job.RunJob(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Name != running.Name {
+		t.Errorf("job should attach to the job which has the same name: %s", j.Name)
+	}
+	if _, err := client.CoreV1().Secrets(j.Namespace).Get(ctx, secretEnvName(j.Name), metav1.GetOptions{}); err == nil {
+		t.Error("secret env of the attached job should not be created")
+	}
+	if err := job.Cleanup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.BatchV1().Jobs(j.Namespace).Get(ctx, running.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("job of another run should not be removed: %v", err)
+	}
+
+	// The job which has the same name is finished, so a new job is run.
+	running.Status.Conditions = []v1.JobCondition{{Type: v1.JobComplete, Status: v1core.ConditionTrue}}
+	if _, err := client.BatchV1().Jobs(running.Namespace).UpdateStatus(ctx, running, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	job.CurrentJob = currentJob.DeepCopy()
+	j, err = job.RunJob(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Name == running.Name || j.Labels[IdempotencyKeyLabel] != hashIdempotencyKey("migrate") {
+		t.Errorf("new job should be created with a random name: %s", j.Name)
+	}
+	secret, err := client.CoreV1().Secrets(j.Namespace).Get(ctx, secretEnvName(j.Name), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].Name != j.Name {
+		t.Errorf("secret is not owned by the job: %v", secret.OwnerReferences)
+	}
+	if err := job.Cleanup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.BatchV1().Jobs(j.Namespace).Get(ctx, j.Name, metav1.GetOptions{}); err == nil {
+		t.Error("job which is created by this run should be removed")
+	}
+}
@@ -8,26 +8,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"net/http"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
-	shellwords "github.com/mattn/go-shellwords"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 
 	"github.com/ghodss/yaml"
 	v1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 // Job has client of kubernetes, current job, command, timeout, and target container information.
 type Job struct {
-	client kubernetes.Interface
+	client     kubernetes.Interface
+	restConfig *rest.Config
 
 	// Batch v1 job struct.
 	CurrentJob *v1.Job
@@ -45,10 +49,94 @@ type Job struct {
 	Container string
 	// If you set 0, timeout is ignored.
 	Timeout time.Duration
+	// Sidecar containers which are stopped after the target container is terminated.
+	SidecarShutdowns []SidecarShutdown
+	// Environment variables which override the target container.
+	Env map[string]string
+	// Environment variables of the target container which are stored in a Secret created with the job.
+	// The values are not written in the job.
+	SecretEnv map[string]string
+	// Files which are mounted into the target container.
+	Files []MountedFile
+	// Files or directories which are copied from the target container after it is terminated.
+	Artifacts []Artifact
+	// Image of the helper container which keeps the artifacts. If you set empty, DefaultArtifactImage is used.
+	ArtifactImage string
+	// Resolve the tags of the images to the digests before creating the job: container or all.
+	// If you set empty, the tags are used.
+	ResolveDigest string
+	// Parameters which are substituted into the args and the env values of the target container.
+	Parameters Parameters
+	// Writer which the logs of the pods are written to. If you set nil, os.Stdout is used.
+	Output io.Writer
+	// Logger to write diagnostic messages. If you set nil, nothing is written.
+	Logger *slog.Logger
+	// Handler which receives lifecycle events of the job.
+	Events EventHandler
+	// If you set true, permissions are not checked in Validate and Preflight.
+	SkipPreflight bool
+	// Policy which the job must satisfy before it is created.
+	Policy *Policy
+	// If you set true, the job is created even if it violates the policy, and the violations are recorded in the annotation.
+	OverridePolicy bool
+	// Policy to run a new job when the job is failed by transient failures.
+	Retry RetryPolicy
+	// Key to prevent duplicate runs. The job is labeled with a hash of the key.
+	IdempotencyKey string
+	// What to do when a job which has the same idempotency key exists: attach, reuse, fail or run.
+	// Default is attach.
+	OnDuplicate string
+	// Name of the lock which is acquired before running the job, to prevent running at the same time.
+	Lock string
+	// If you set true, wait until the lock is released by another holder. Otherwise returns error.
+	LockWait bool
+	// Identity of the lock holder. If you set empty, user@hostname:pid is used.
+	LockIdentity string
+	// ID of the run, which is recorded in the label of the job.
+	RunID string
+	// Seconds to remove the job by Kubernetes after the job is finished.
+	// It is a safety net when kube-job can not clean up the job. If you set nil, the value in the template is used.
+	TTLAfterFinished *int32
+	// Node selector which is merged into the pod template.
+	NodeSelector map[string]string
+	// Tolerations which are added to the pod template.
+	Tolerations []corev1.Toleration
+	// Affinity which overrides the pod template.
+	Affinity *corev1.Affinity
+	// Priority class name which overrides the pod template.
+	PriorityClassName string
+	// Service account name which overrides the pod template.
+	ServiceAccountName string
+	// Active deadline seconds which overrides the job spec.
+	ActiveDeadlineSeconds *int64
+	// Backoff limit which overrides the job spec.
+	BackoffLimit *int32
+	// Labels which are added to the job.
+	Labels map[string]string
+	// Annotations which are added to the job.
+	Annotations map[string]string
+	// Labels which are added to the pod template.
+	PodLabels map[string]string
+	// Annotations which are added to the pod template.
+	PodAnnotations map[string]string
+
+	// Name of the job before adding random string.
+	templateName string
+	// Path or URL of the job template.
+	templateSource string
+	// Client of the container registry to resolve the digests.
+	registry *registryClient
+	// Job of another run which has the same idempotency key, when the run is attached to it.
+	// The job of another run is not removed.
+	attached *v1.Job
+	// Whether the job is run again by the retry policy. Duplicates are not looked up in retries,
+	// because the failed job of the run would be selected again.
+	retried bool
 }
 
 // NewJob returns a new Job struct, and initialize kubernetes client.
 // It read the job definition yaml file, and unmarshal to batch/v1/Job.
+// It is a thin wrapper of New, please use New with options for more overrides.
 func NewJob(configFile, currentFile, name, command, image, resources, namespace, container string, timeout time.Duration) (*Job, error) {
 	if len(configFile) == 0 {
 		return nil, errors.New("Config file is required")
@@ -56,17 +144,38 @@ func NewJob(configFile, currentFile, name, command, image, resources, namespace,
 	if len(currentFile) == 0 {
 		return nil, errors.New("Template file is required")
 	}
-	var resourceRequirements corev1.ResourceRequirements
-	if len(resources) != 0 {
-		if err := json.Unmarshal([]byte(resources), &resourceRequirements); err != nil {
-			return nil, err
-		}
-	}
-	client, err := newClient(os.ExpandEnv(configFile))
+	resourceRequirements, err := ParseResources(resources)
 	if err != nil {
 		return nil, err
 	}
-	downloaded, err := downloadFile(currentFile)
+	return New(
+		context.Background(),
+		WithKubeconfig(configFile),
+		WithTemplate(currentFile),
+		WithName(name),
+		WithArgsString(command),
+		WithImage(image),
+		WithResources(resourceRequirements),
+		WithNamespace(namespace),
+		WithContainer(container),
+		WithTimeout(timeout),
+	)
+}
+
+// ParseResources parses the resource requirements in JSON, like {"requests":{"cpu":"100m"}}.
+// If the value is empty, it returns empty requirements.
+func ParseResources(value string) (corev1.ResourceRequirements, error) {
+	var resources corev1.ResourceRequirements
+	if len(value) == 0 {
+		return resources, nil
+	}
+	err := json.Unmarshal([]byte(value), &resources)
+	return resources, err
+}
+
+// readTemplate reads the job template from the file or URL.
+func readTemplate(ctx context.Context, file string) (*v1.Job, error) {
+	downloaded, err := downloadFile(ctx, file)
 	if err != nil {
 		return nil, err
 	}
@@ -79,43 +188,15 @@ func NewJob(configFile, currentFile, name, command, image, resources, namespace,
 	if err != nil {
 		return nil, err
 	}
-	jobName := currentJob.Name
-	if len(name) > 0 {
-		jobName = name
-	}
-	currentJob.SetName(generateRandomName(jobName))
-	if len(namespace) > 0 {
-		currentJob.SetNamespace(namespace)
-	}
-	p := shellwords.NewParser()
-	args, err := p.Parse(command)
-	log.Info("Received args:")
-	for _, arg := range args {
-		log.Info(arg)
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	return &Job{
-		client,
-		&currentJob,
-		name,
-		args,
-		image,
-		resourceRequirements,
-		namespace,
-		container,
-		timeout,
-	}, nil
+	return &currentJob, nil
 }
 
-func downloadFile(rawurl string) (string, error) {
+func downloadFile(ctx context.Context, rawurl string) (string, error) {
 	if !strings.HasPrefix(rawurl, "https://") {
 		return rawurl, nil
 	}
 
-	req, err := http.NewRequest("GET", rawurl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", rawurl, nil)
 	if err != nil {
 		return rawurl, err
 	}
@@ -150,6 +231,14 @@ func downloadFile(rawurl string) (string, error) {
 	return downloaded, err
 }
 
+// baseName returns the name of the job before adding random string.
+func (j *Job) baseName() string {
+	if len(j.templateName) > 0 {
+		return j.templateName
+	}
+	return j.CurrentJob.Name
+}
+
 func generateRandomName(name string) string {
 	// label must be no more than 63 characters long
 	lengthToGenerate := math.Min(float64(62-len(name)), float64(32))
@@ -166,15 +255,133 @@ func secureRandomStr(b int) string {
 }
 
 // Validate checks job templates before run the job.
-func (j *Job) Validate() error {
-	_, err := findContainerIndex(j.CurrentJob, j.Container)
-	return err
+// Unless SkipPreflight is set, it also checks that the user has all permissions to run and clean up the job
+// in the namespace, so it accesses the API server.
+func (j *Job) Validate(ctx context.Context) error {
+	if err := j.validate(); err != nil {
+		return err
+	}
+	return j.Preflight(ctx, true)
+}
+
+// validate checks the job template and the overrides without accessing the API server.
+func (j *Job) validate() error {
+	if _, err := findContainerIndex(j.CurrentJob, j.Container); err != nil {
+		return err
+	}
+	if err := validateOnDuplicate(j.OnDuplicate); err != nil {
+		return err
+	}
+	if err := validateResolveDigest(j.ResolveDigest); err != nil {
+		return err
+	}
+	if err := validateFiles(j.Files); err != nil {
+		return err
+	}
+	if err := validateSecretEnv(j.SecretEnv); err != nil {
+		return err
+	}
+	if err := validateArtifacts(j.Artifacts); err != nil {
+		return err
+	}
+	if err := j.validateScheduling(); err != nil {
+		return err
+	}
+	return j.validateMetadata()
 }
 
 // RunJob is run a kubernetes job, and returns the job information.
-func (j *Job) RunJob() (*v1.Job, error) {
-	ctx := context.Background()
+// When IdempotencyKey is set, the name of the job is derived from the key, so only one of the runs which start
+// at the same time can create the job, and the others attach to it according to OnDuplicate.
+func (j *Job) RunJob(ctx context.Context) (*v1.Job, error) {
+	claim := len(j.IdempotencyKey) > 0 && j.OnDuplicate != DuplicateRun && !j.retried
+	base := j.baseName()
+	if claim {
+		duplicate, err := j.findDuplicateJob(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if duplicate != nil {
+			j.attachDuplicateJob(duplicate)
+			return j.attached, nil
+		}
+		j.CurrentJob.SetName(idempotentName(base, j.IdempotencyKey))
+	}
+	resultJob, err := j.createJob(ctx, claim)
+	if claim && kerrors.IsAlreadyExists(err) {
+		duplicate, e := j.existingDuplicateJob(ctx)
+		if e != nil {
+			return nil, e
+		}
+		if duplicate != nil {
+			j.attachDuplicateJob(duplicate)
+			return j.attached, nil
+		}
+		// The job which has the name is already finished, so a new job is run with a random name.
+		j.CurrentJob.SetName(generateRandomName(base))
+		resultJob, err = j.createJob(ctx, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	j.attached = nil
+	emitEvent(j.Events, resultJob, Event{Type: EventJobCreated})
+	return resultJob, nil
+}
+
+// createJob builds and creates the job with the objects of the job.
+// When claim is set, the job is created before the objects, because the name of the job may be taken by another run.
+func (j *Job) createJob(ctx context.Context, claim bool) (*v1.Job, error) {
+	currentJob, err := j.BuildJob()
+	if err != nil {
+		return nil, err
+	}
+	if len(j.ResolveDigest) > 0 {
+		if err := j.resolveDigests(ctx, currentJob); err != nil {
+			return nil, err
+		}
+	}
+	if err := j.checkPolicy(currentJob); err != nil {
+		return nil, err
+	}
+	if len(j.IdempotencyKey) > 0 {
+		currentJob.Labels[IdempotencyKeyLabel] = hashIdempotencyKey(j.IdempotencyKey)
+	}
+	if j.hasObjects() && !claim {
+		if err := j.createObjects(ctx, currentJob, false); err != nil {
+			// Some of the objects may be created before the error.
+			j.removeObjectsDetached(ctx, currentJob)
+			return nil, err
+		}
+	}
+	resultJob, err := j.client.BatchV1().Jobs(j.CurrentJob.Namespace).Create(ctx, currentJob, metav1.CreateOptions{})
+	if err != nil {
+		if j.hasObjects() && !claim {
+			j.removeObjectsDetached(ctx, currentJob)
+		}
+		return nil, err
+	}
+	if !j.hasObjects() {
+		return resultJob, nil
+	}
+	if claim {
+		if err := j.createObjects(ctx, resultJob, true); err != nil {
+			removeCtx, cancel := detachedContext(ctx)
+			defer cancel()
+			if e := j.cleanup(removeCtx); e != nil {
+				j.logger().Warn("Failed to remove the job", "job", resultJob.Name, "error", e)
+			}
+			return nil, err
+		}
+	} else if err := j.ownObjects(ctx, resultJob); err != nil {
+		// The objects are still removed in the cleanup.
+		j.logger().Warn("Failed to set the owner of the objects", "job", resultJob.Name, "error", err)
+	}
+	return resultJob, nil
+}
 
+// BuildJob returns the job which is created by RunJob, with all overrides applied to the template.
+func (j *Job) BuildJob() (*v1.Job, error) {
 	currentJob := j.CurrentJob.DeepCopy()
 	index, err := findContainerIndex(currentJob, j.Container)
 
@@ -193,11 +400,53 @@ func (j *Job) RunJob() (*v1.Job, error) {
 	if j.Resources.Limits != nil {
 		currentJob.Spec.Template.Spec.Containers[index].Resources.Limits = j.Resources.Limits
 	}
-	resultJob, err := j.client.BatchV1().Jobs(j.CurrentJob.Namespace).Create(ctx, currentJob, metav1.CreateOptions{})
-	if err != nil {
-		return nil, err
+	if len(j.Env) > 0 {
+		overrideEnv(&currentJob.Spec.Template.Spec.Containers[index], j.Env)
 	}
-	return resultJob, nil
+	if len(j.SecretEnv) > 0 {
+		j.applySecretEnv(currentJob, index)
+	}
+	if j.TTLAfterFinished != nil {
+		currentJob.Spec.TTLSecondsAfterFinished = j.TTLAfterFinished
+	}
+	if len(j.Files) > 0 {
+		j.applyFiles(currentJob, index)
+	}
+	j.applyScheduling(currentJob)
+	if len(j.Artifacts) > 0 {
+		// The helper container waits until activeDeadlineSeconds, which is set in the scheduling.
+		if err := j.applyArtifacts(currentJob, index); err != nil {
+			return nil, err
+		}
+	}
+	j.applyMetadata(currentJob)
+	if len(j.Parameters) > 0 {
+		substituteParameters(&currentJob.Spec.Template.Spec.Containers[index], j.Parameters)
+	}
+	currentJob.Labels = mergeMap(currentJob.Labels, j.managedLabels(currentJob.Spec.Template.Spec.Containers[index].Name))
+	return currentJob, nil
+}
+
+// overrideEnv replaces the values of the environment variables in the container, and adds the others.
+func overrideEnv(container *corev1.Container, env map[string]string) {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		setEnv(container, corev1.EnvVar{Name: k, Value: env[k]})
+	}
+}
+
+// setEnv replaces the environment variable which has the same name, or appends it.
+func setEnv(container *corev1.Container, env corev1.EnvVar) {
+	index := slices.IndexFunc(container.Env, func(e corev1.EnvVar) bool { return e.Name == env.Name })
+	if index < 0 {
+		container.Env = append(container.Env, env)
+		return
+	}
+	container.Env[index] = env
 }
 
 // findContainerIndex finds target container from job definition.
@@ -216,7 +465,7 @@ func findContainerIndex(job *v1.Job, containerName string) (int, error) {
 
 // WaitJob waits response of the job.
 func (j *Job) WaitJob(ctx context.Context, job *v1.Job, ignoreSidecar bool) error {
-	log.Info("Waiting for running job...")
+	j.logger().Info("Waiting for running job...", "job", job.Name)
 
 	errCh := make(chan error, 1)
 	done := make(chan struct{}, 1)
@@ -233,8 +482,10 @@ func (j *Job) WaitJob(ctx context.Context, job *v1.Job, ignoreSidecar bool) erro
 			return err
 		}
 	case <-done:
-		log.Info("Job is succeeded")
 	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return errors.New("process is canceled")
+		}
 		return errors.New("process timeout")
 	}
 
@@ -244,34 +495,113 @@ func (j *Job) WaitJob(ctx context.Context, job *v1.Job, ignoreSidecar bool) erro
 // WaitJobComplete waits the completion of the job.
 // If the job is failed, this function returns error.
 // If the job is succeeded, this function returns nil.
+// When SidecarShutdowns are set, sidecar containers are stopped after the target container is terminated,
+// and the result of the target container is used as the result of the job.
+// When Events is set, pod and container events are emitted while waiting, and EventJobFinished is emitted at the end.
 func (j *Job) WaitJobComplete(ctx context.Context, job *v1.Job, ignoreSidecar bool) error {
+	err := j.waitJobComplete(ctx, job, ignoreSidecar)
+	if ctx.Err() == nil {
+		emitEvent(j.Events, job, Event{Type: EventJobFinished, Err: err})
+	}
+	return err
+}
+
+func (j *Job) waitJobComplete(ctx context.Context, job *v1.Job, ignoreSidecar bool) error {
+	containerName, err := j.targetContainerName(job)
+	if err != nil {
+		return err
+	}
+	signaled := map[string]bool{}
+	collected := map[string]bool{}
+	var artifactsErr error
+	// result returns the error of the artifacts when the job itself is succeeded.
+	result := func(err error) error {
+		if err != nil {
+			return err
+		}
+		if len(j.Artifacts) > 0 && len(collected) == 0 {
+			return errors.New("Artifacts are not copied from any pod of the job")
+		}
+		return artifactsErr
+	}
+	tracker := newPodTracker(j.Events, job)
 retry:
 	for {
-		time.Sleep(3 * time.Second)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(3 * time.Second):
+		}
 		running, err := j.client.BatchV1().Jobs(job.Namespace).Get(ctx, job.Name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) && job.Spec.TTLSecondsAfterFinished != nil {
+			return fmt.Errorf("Job %s is removed by ttlSecondsAfterFinished before checking the result, please set longer TTL", job.Name)
+		}
 		if err != nil {
 			return err
 		}
-		if running.Status.Active == 0 && (running.Status.Succeeded == 1 || running.Status.Failed == 1) {
-			return checkJobConditions(running.Status.Conditions)
+		if j.Events != nil {
+			if pods, err := j.FindPods(ctx, running); err == nil {
+				tracker.observe(pods)
+			}
 		}
-		if ignoreSidecar {
-			pods, err := j.FindPods(ctx, running)
-			if err != nil {
-				return err
+		if running.Status.Active == 0 && (running.Status.Succeeded == 1 || running.Status.Failed == 1) || jobIsFinished(running) {
+			if len(signaled) > 0 {
+				pods, err := j.FindPods(ctx, running)
+				if err != nil {
+					return err
+				}
+				if finished, err := checkPodConditions(pods, containerName); finished {
+					return result(err)
+				}
 			}
-			finished, err := checkPodConditions(pods, j.Container)
-			if finished {
-				log.Warn("Pod is still running, but specified container is terminated, so job will be removed")
-				return err
+			return result(checkJobConditions(running.Status.Conditions))
+		}
+		if !ignoreSidecar && len(j.SidecarShutdowns) == 0 && len(j.Artifacts) == 0 {
+			continue retry
+		}
+		pods, err := j.FindPods(ctx, running)
+		if err != nil {
+			return err
+		}
+		finished, err := checkPodConditions(pods, containerName)
+		if !finished {
+			continue retry
+		}
+		if len(j.Artifacts) > 0 {
+			if e := j.collectArtifacts(ctx, pods, containerName, collected); e != nil {
+				artifactsErr = e
 			}
 		}
-		continue retry
-
+		if len(j.SidecarShutdowns) > 0 {
+			// Failures are logged in shutdownSidecars. The job is still removed when ignoreSidecar is set.
+			j.shutdownSidecars(ctx, pods, signaled)
+		}
+		if (len(j.SidecarShutdowns) > 0 || len(j.Artifacts) > 0) && !ignoreSidecar {
+			// Wait until the job is finished after the sidecars and the helper container exit.
+			continue retry
+		}
+		j.logger().Warn("Pod is still running, but specified container is terminated, so job will be removed", "job", job.Name)
+		return result(err)
 	}
 
 }
 
+// targetContainerName returns the name of the container where arguments are substituted.
+func (j *Job) targetContainerName(job *v1.Job) (string, error) {
+	if len(j.Container) > 0 {
+		return j.Container, nil
+	}
+	// The job may have containers which are added by kube-job, like the helper container of the artifacts.
+	if name := job.Labels[ContainerLabel]; len(name) > 0 {
+		return name, nil
+	}
+	index, err := findContainerIndex(job, j.Container)
+	if err != nil {
+		return "", err
+	}
+	return job.Spec.Template.Spec.Containers[index].Name, nil
+}
+
 // FindPods finds pod in the job.
 func (j *Job) FindPods(ctx context.Context, job *v1.Job) ([]corev1.Pod, error) {
 	labels := parseLabels(job.Spec.Template.Labels)
@@ -334,16 +664,10 @@ func podIncludeContainer(pod corev1.Pod, containerName string) bool {
 	return false
 }
 
+// containerIsCompleted checks whether the container in the pod is terminated.
+// The exit code of the container is preferred to the phase of the pod,
+// because sidecar containers can fail the pod after the container is succeeded.
 func containerIsCompleted(pod corev1.Pod, containerName string) (bool, error) {
-	if pod.Status.Phase == corev1.PodSucceeded {
-		return true, nil
-	}
-	if pod.Status.Phase == corev1.PodFailed {
-		return true, fmt.Errorf("%s Pod is failed", pod.Name)
-	}
-	if pod.Status.Phase == corev1.PodPending {
-		return false, nil
-	}
 	for _, status := range pod.Status.ContainerStatuses {
 		if status.Name == containerName && status.State.Terminated != nil {
 			if status.State.Terminated.ExitCode == 0 {
@@ -352,25 +676,50 @@ func containerIsCompleted(pod corev1.Pod, containerName string) (bool, error) {
 			return true, fmt.Errorf("Container is failed: %s", status.State.Terminated.Reason)
 		}
 	}
+	if pod.Status.Phase == corev1.PodSucceeded {
+		return true, nil
+	}
+	if pod.Status.Phase == corev1.PodFailed {
+		return true, fmt.Errorf("%s Pod is failed", pod.Name)
+	}
 	return false, nil
 }
 
 // Cleanup removes the job from the kubernetes cluster.
-func (j *Job) Cleanup() error {
-	ctx := context.Background()
-	log.Infof("Removing the job: %s", j.CurrentJob.Name)
+func (j *Job) Cleanup(ctx context.Context) error {
+	err := j.cleanup(ctx)
+	emitEvent(j.Events, j.CurrentJob, Event{Type: EventCleanupDone, Err: err})
+	return err
+}
+
+func (j *Job) cleanup(ctx context.Context) error {
+	if j.attached != nil {
+		j.logger().Info("The job is created by another run, so it is not removed", "job", j.CurrentJob.Name)
+		return nil
+	}
+	j.logger().Info("Removing the job", "job", j.CurrentJob.Name)
 	options := metav1.DeleteOptions{}
 	err := j.client.BatchV1().Jobs(j.CurrentJob.Namespace).Delete(ctx, j.CurrentJob.Name, options)
-	if err != nil {
+	if kerrors.IsNotFound(err) {
+		// The job may be already removed by ttlSecondsAfterFinished or someone else.
+		j.logger().Info("The job is already removed", "job", j.CurrentJob.Name)
+	} else if err != nil {
 		return err
 	}
-	return j.removePods(ctx)
+	if err := j.removePods(ctx); err != nil {
+		return err
+	}
+	if j.hasObjects() {
+		j.logger().Info("Removing the objects of the job", "job", j.CurrentJob.Name)
+		return j.removeObjects(ctx, j.CurrentJob)
+	}
+	return nil
 }
 
 func (j *Job) removePods(ctx context.Context) error {
 	// Use job-name to find pods which are related the job.
 	labels := "job-name=" + j.CurrentJob.Name
-	log.Infof("Remove related pods which labels is: %s", labels)
+	j.logger().Info("Remove related pods", "labels", labels)
 	listOptions := metav1.ListOptions{
 		LabelSelector: labels,
 	}
@@ -11,17 +11,23 @@ import (
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/batch/v1"
 	v1core "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	batchv1 "k8s.io/client-go/kubernetes/typed/batch/v1"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
 type mockedKubernetes struct {
 	kubernetes.Interface
-	mockedBatch batchv1.BatchV1Interface
-	mockedCore  corev1.CoreV1Interface
+	mockedBatch         batchv1.BatchV1Interface
+	mockedCore          corev1.CoreV1Interface
+	mockedCoordination  coordinationv1.CoordinationV1Interface
+	mockedAuthorization authorizationv1.AuthorizationV1Interface
 }
 
 type mockedBatchV1 struct {
@@ -31,7 +37,9 @@ type mockedBatchV1 struct {
 
 type mockedJob struct {
 	batchv1.JobInterface
-	job *v1.Job
+	job       *v1.Job
+	jobList   *v1.JobList
+	deleteErr error
 }
 
 type mockedCoreV1 struct {
@@ -46,7 +54,10 @@ type mockedPod struct {
 	podList *v1core.PodList
 }
 
-func (m mockedJob) Create(context.Context, *v1.Job, metav1.CreateOptions) (*v1.Job, error) {
+func (m mockedJob) Create(ctx context.Context, job *v1.Job, options metav1.CreateOptions) (*v1.Job, error) {
+	if m.job == nil {
+		return job, nil
+	}
 	return m.job, nil
 }
 
@@ -54,8 +65,15 @@ func (m mockedJob) Get(context.Context, string, metav1.GetOptions) (*v1.Job, err
 	return m.job, nil
 }
 
+func (m mockedJob) List(context.Context, metav1.ListOptions) (*v1.JobList, error) {
+	if m.jobList == nil {
+		return &v1.JobList{}, nil
+	}
+	return m.jobList, nil
+}
+
 func (m mockedJob) Delete(context.Context, string, metav1.DeleteOptions) error {
-	return nil
+	return m.deleteErr
 }
 
 func (m mockedPod) DeleteCollection(ctx context.Context, deleteOptions metav1.DeleteOptions, options metav1.ListOptions) error {
@@ -85,6 +103,14 @@ func (m mockedKubernetes) CoreV1() corev1.CoreV1Interface {
 	return m.mockedCore
 }
 
+func (m mockedKubernetes) CoordinationV1() coordinationv1.CoordinationV1Interface {
+	return m.mockedCoordination
+}
+
+func (m mockedKubernetes) AuthorizationV1() authorizationv1.AuthorizationV1Interface {
+	return m.mockedAuthorization
+}
+
 func TestGenerateRandomName(t *testing.T) {
 	name := generateRandomName("foo")
 	if len(name) != 3+1+32 {
@@ -133,7 +159,7 @@ func TestRunJob(t *testing.T) {
 		},
 	}
 
-	j, err := job.RunJob()
+	j, err := job.RunJob(context.Background())
 	if err != nil {
 		t.Error(err)
 	}
@@ -142,6 +168,35 @@ func TestRunJob(t *testing.T) {
 	}
 }
 
+func TestRunJobWithTTLAfterFinished(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	ttl := int32(3600)
+	job := &Job{
+		CurrentJob:       currentJob,
+		Container:        "alpine",
+		TTLAfterFinished: &ttl,
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: mockedJob{},
+			},
+		},
+	}
+
+	j, err := job.RunJob(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+	if j.Spec.TTLSecondsAfterFinished == nil || *j.Spec.TTLSecondsAfterFinished != 3600 {
+		t.Errorf("ttlSecondsAfterFinished is not set: %v", j.Spec.TTLSecondsAfterFinished)
+	}
+	if currentJob.Spec.TTLSecondsAfterFinished != nil {
+		t.Error("template should not be changed")
+	}
+}
+
 func TestCheckJobConditions(t *testing.T) {
 	complete := []v1.JobCondition{
 		v1.JobCondition{
@@ -458,7 +513,7 @@ func TestCompleteTargetContainer(t *testing.T) {
 					Name: "alpine",
 					State: v1core.ContainerState{
 						Terminated: &v1core.ContainerStateTerminated{
-							ExitCode: 0,
+							ExitCode: 1,
 						},
 					},
 				},
@@ -466,6 +521,42 @@ func TestCompleteTargetContainer(t *testing.T) {
 		},
 	}
 	failedPod.Name = "failed"
+	failedSidecarPod := v1core.Pod{
+		Spec: v1core.PodSpec{
+			Containers: []v1core.Container{
+				{
+					Name:  "alpine",
+					Image: "alpine",
+				},
+				{
+					Name:  "istio-proxy",
+					Image: "istio/proxyv2",
+				},
+			},
+		},
+		Status: v1core.PodStatus{
+			Phase: v1core.PodFailed,
+			ContainerStatuses: []v1core.ContainerStatus{
+				v1core.ContainerStatus{
+					Name: "alpine",
+					State: v1core.ContainerState{
+						Terminated: &v1core.ContainerStateTerminated{
+							ExitCode: 0,
+						},
+					},
+				},
+				v1core.ContainerStatus{
+					Name: "istio-proxy",
+					State: v1core.ContainerState{
+						Terminated: &v1core.ContainerStateTerminated{
+							ExitCode: 137,
+						},
+					},
+				},
+			},
+		},
+	}
+	failedSidecarPod.Name = "failed-sidecar"
 
 	pods := []v1core.Pod{
 		successPod,
@@ -503,6 +594,18 @@ func TestCompleteTargetContainer(t *testing.T) {
 		t.Error(errors.New("failed pod should have error"))
 	}
 
+	pods = []v1core.Pod{
+		successContainer,
+		failedSidecarPod,
+	}
+	completed, err = checkPodConditions(pods, "alpine")
+	if completed != true {
+		t.Error(errors.New("failed sidecar pod should be completed"))
+	}
+	if err != nil {
+		t.Error(errors.New("failed sidecar pod should not have error"))
+	}
+
 	pods = []v1core.Pod{
 		successContainer,
 		failedContainer,
@@ -581,3 +684,58 @@ func TestRemovePods(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCleanupRemovedJob(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	job := &Job{
+		CurrentJob: currentJob,
+		Container:  "alpine",
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: mockedJob{
+					deleteErr: kerrors.NewNotFound(schema.GroupResource{Group: "batch", Resource: "jobs"}, currentJob.Name),
+				},
+			},
+			mockedCore: mockedCoreV1{
+				mockedPod: mockedPod{
+					jobName: currentJob.Name,
+				},
+			},
+		},
+	}
+	if err := job.Cleanup(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunJobWithEnv(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	job := &Job{
+		CurrentJob: currentJob,
+		Container:  "alpine",
+		Env:        map[string]string{"HOGE": "piyo", "RAILS_ENV": "production"},
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: mockedJob{},
+			},
+		},
+	}
+
+	j, err := job.RunJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := j.Spec.Template.Spec.Containers[0].Env
+	if len(env) != 2 || env[0].Name != "HOGE" || env[0].Value != "piyo" || env[1].Name != "RAILS_ENV" || env[1].Value != "production" {
+		t.Errorf("env is not overridden: %v", env)
+	}
+	if currentJob.Spec.Template.Spec.Containers[0].Env[0].Value != "fuga" {
+		t.Error("template should not be changed")
+	}
+}
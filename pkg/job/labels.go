@@ -0,0 +1,69 @@
+package job
+
+import (
+	"os/user"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ManagedByLabel is a label of the jobs which are created by kube-job.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	// ManagedByValue is a value of ManagedByLabel.
+	ManagedByValue = "kube-job"
+	// TemplateLabel is a label of the job which has the name of the job template.
+	TemplateLabel = "kube-job/template"
+	// ContainerLabel is a label of the job which has the target container name.
+	ContainerLabel = "kube-job/container"
+	// UserLabel is a label of the job which has the user who runs kube-job.
+	UserLabel = "kube-job/user"
+	// RunIDLabel is a label of the job which has the ID of the run.
+	// Jobs which are created by retries or a matrix in the same run have the same ID.
+	RunIDLabel = "kube-job/run-id"
+	// IdempotencyKeyLabel is a label of the job which has a hash of the idempotency key.
+	IdempotencyKeyLabel = "kube-job/idempotency-key"
+
+	// VersionAnnotation is an annotation of the job which has the version of kube-job.
+	VersionAnnotation = "kube-job/version"
+	// UserAnnotation is an annotation of the job which has the user who runs kube-job.
+	// The label has a sanitized value, but the annotation has the original value.
+	UserAnnotation = "kube-job/user"
+	// TemplateSourceAnnotation is an annotation of the job which has the path or URL of the job template.
+	TemplateSourceAnnotation = "kube-job/template-source"
+	// PolicyOverrideAnnotation is an annotation of the job which has the policy violations overridden by the user.
+	PolicyOverrideAnnotation = "kube-job/policy-override"
+	// OriginalImagesAnnotation is an annotation of the job which has the images before they are pinned to the digests,
+	// like NAME=IMAGE separated by commas.
+	OriginalImagesAnnotation = "kube-job/original-images"
+)
+
+var invalidLabelValue = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sanitizeLabelValue replaces invalid characters for a label value, and truncates it to 63 characters.
+func sanitizeLabelValue(value string) string {
+	value = invalidLabelValue.ReplaceAllString(value, "-")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	return strings.Trim(value, "._-")
+}
+
+// currentUser returns the name of the user who runs kube-job.
+func currentUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return "unknown"
+	}
+	return u.Username
+}
+
+// managedLabels returns labels which are added to the job created by kube-job.
+func (j *Job) managedLabels(containerName string) map[string]string {
+	return map[string]string{
+		ManagedByLabel: ManagedByValue,
+		TemplateLabel:  sanitizeLabelValue(j.baseName()),
+		ContainerLabel: containerName,
+		UserLabel:      sanitizeLabelValue(currentUser()),
+		RunIDLabel:     j.RunID,
+	}
+}
@@ -0,0 +1,165 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// StatusRunning is a status of the job which is not finished.
+	StatusRunning = "Running"
+	// StatusSucceeded is a status of the job which has Complete condition.
+	StatusSucceeded = "Succeeded"
+	// StatusFailed is a status of the job which has Failed condition.
+	StatusFailed = "Failed"
+)
+
+// JobSummary is a summary of the job which is created by kube-job.
+type JobSummary struct {
+	Namespace string     `json:"namespace"`
+	Name      string     `json:"name"`
+	Template  string     `json:"template"`
+	User      string     `json:"user"`
+	RunID     string     `json:"runID"`
+	Status    string     `json:"status"`
+	Created   time.Time  `json:"created"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	// Exit code of the target container in the latest pod.
+	ExitCode *int32 `json:"exitCode,omitempty"`
+}
+
+// Age returns elapsed time since the job is created.
+func (s JobSummary) Age(now time.Time) time.Duration {
+	return now.Sub(s.Created)
+}
+
+// Duration returns the running time of the job. If the job is not started, it returns 0.
+func (s JobSummary) Duration(now time.Time) time.Duration {
+	if s.Started == nil {
+		return 0
+	}
+	if s.Finished == nil {
+		return now.Sub(*s.Started)
+	}
+	return s.Finished.Sub(*s.Started)
+}
+
+// ListOptions filters jobs which are created by kube-job.
+type ListOptions struct {
+	// Namespace of the jobs. If you set empty, jobs in all namespaces are listed.
+	Namespace string
+	// Name of the job template.
+	Template string
+	// Running, Succeeded or Failed.
+	Status string
+}
+
+// ListJobs lists jobs which are created by kube-job, sorted by the creation time.
+func ListJobs(ctx context.Context, client kubernetes.Interface, options ListOptions) ([]JobSummary, error) {
+	switch options.Status {
+	case "", StatusRunning, StatusSucceeded, StatusFailed:
+	default:
+		return nil, fmt.Errorf("Invalid status %q, please set 'Running', 'Succeeded' or 'Failed'", options.Status)
+	}
+	selector := ManagedByLabel + "=" + ManagedByValue
+	if len(options.Template) > 0 {
+		selector += "," + TemplateLabel + "=" + sanitizeLabelValue(options.Template)
+	}
+	jobList, err := client.BatchV1().Jobs(options.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	podList, err := client.CoreV1().Pods(options.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "job-name",
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := map[string][]corev1.Pod{}
+	for _, pod := range podList.Items {
+		key := pod.Namespace + "/" + pod.Labels["job-name"]
+		pods[key] = append(pods[key], pod)
+	}
+
+	summaries := []JobSummary{}
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		summary := summarizeJob(job, pods[job.Namespace+"/"+job.Name])
+		if len(options.Status) > 0 && summary.Status != options.Status {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Created.Before(summaries[j].Created)
+	})
+	return summaries, nil
+}
+
+// summarizeJob builds a summary from the job and the pods of the job.
+func summarizeJob(job *v1.Job, pods []corev1.Pod) JobSummary {
+	summary := JobSummary{
+		Namespace: job.Namespace,
+		Name:      job.Name,
+		Template:  job.Labels[TemplateLabel],
+		User:      job.Labels[UserLabel],
+		RunID:     job.Labels[RunIDLabel],
+		Status:    StatusRunning,
+		Created:   job.CreationTimestamp.Time,
+	}
+	if job.Status.StartTime != nil {
+		started := job.Status.StartTime.Time
+		summary.Started = &started
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case v1.JobComplete:
+			summary.Status = StatusSucceeded
+		case v1.JobFailed:
+			summary.Status = StatusFailed
+		default:
+			continue
+		}
+		finished := condition.LastTransitionTime.Time
+		if job.Status.CompletionTime != nil {
+			finished = job.Status.CompletionTime.Time
+		}
+		summary.Finished = &finished
+	}
+	summary.ExitCode = latestExitCode(pods, job.Labels[ContainerLabel])
+	return summary
+}
+
+// latestExitCode returns the exit code of the container in the latest pod.
+// If the container is not terminated, it returns nil.
+func latestExitCode(pods []corev1.Pod, containerName string) *int32 {
+	var latest *corev1.Pod
+	for i := range pods {
+		if latest == nil || latest.CreationTimestamp.Before(&pods[i].CreationTimestamp) {
+			latest = &pods[i]
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	for _, status := range latest.Status.ContainerStatuses {
+		if status.Name == containerName && status.State.Terminated != nil {
+			code := status.State.Terminated.ExitCode
+			return &code
+		}
+	}
+	return nil
+}
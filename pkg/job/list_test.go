@@ -0,0 +1,116 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/batch/v1"
+	v1core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSanitizeLabelValue(t *testing.T) {
+	if value := sanitizeLabelValue(`DOMAIN\alice`); value != "DOMAIN-alice" {
+		t.Errorf("label value does not match: %s", value)
+	}
+	if value := sanitizeLabelValue("-alice@example.com-"); value != "alice-example.com" {
+		t.Errorf("label value does not match: %s", value)
+	}
+}
+
+func TestListJobs(t *testing.T) {
+	now := time.Now()
+	succeeded := v1.Job{
+		Status: v1.JobStatus{
+			StartTime:      &metav1.Time{Time: now.Add(-10 * time.Minute)},
+			CompletionTime: &metav1.Time{Time: now.Add(-8 * time.Minute)},
+			Conditions: []v1.JobCondition{
+				{
+					Type:   v1.JobComplete,
+					Status: v1core.ConditionTrue,
+				},
+			},
+		},
+	}
+	succeeded.Name = "succeeded"
+	succeeded.Namespace = "default"
+	succeeded.CreationTimestamp = metav1.NewTime(now.Add(-10 * time.Minute))
+	succeeded.Labels = map[string]string{
+		ManagedByLabel: ManagedByValue,
+		TemplateLabel:  "example-job",
+		ContainerLabel: "alpine",
+	}
+	running := v1.Job{
+		Status: v1.JobStatus{
+			StartTime: &metav1.Time{Time: now.Add(-1 * time.Minute)},
+		},
+	}
+	running.Name = "running"
+	running.Namespace = "default"
+	running.CreationTimestamp = metav1.NewTime(now.Add(-1 * time.Minute))
+	running.Labels = succeeded.Labels
+
+	pod := v1core.Pod{
+		Status: v1core.PodStatus{
+			ContainerStatuses: []v1core.ContainerStatus{
+				{
+					Name: "alpine",
+					State: v1core.ContainerState{
+						Terminated: &v1core.ContainerStateTerminated{
+							ExitCode: 3,
+						},
+					},
+				},
+			},
+		},
+	}
+	pod.Namespace = "default"
+	pod.Labels = map[string]string{"job-name": "succeeded"}
+
+	client := mockedKubernetes{
+		mockedBatch: mockedBatchV1{
+			mockedJob: mockedJob{
+				jobList: &v1.JobList{
+					Items: []v1.Job{running, succeeded},
+				},
+			},
+		},
+		mockedCore: mockedCoreV1{
+			mockedPod: mockedPod{
+				podList: &v1core.PodList{
+					Items: []v1core.Pod{pod},
+				},
+			},
+		},
+	}
+
+	summaries, err := ListJobs(context.Background(), client, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].Name != "succeeded" || summaries[1].Name != "running" {
+		t.Fatalf("summaries do not match: %v", summaries)
+	}
+	if summaries[0].Status != StatusSucceeded || summaries[0].Duration(now) != 2*time.Minute {
+		t.Errorf("succeeded summary does not match: %v", summaries[0])
+	}
+	if summaries[0].ExitCode == nil || *summaries[0].ExitCode != 3 {
+		t.Errorf("exit code does not match: %v", summaries[0].ExitCode)
+	}
+	if summaries[1].Status != StatusRunning || summaries[1].ExitCode != nil {
+		t.Errorf("running summary does not match: %v", summaries[1])
+	}
+
+	summaries, err = ListJobs(context.Background(), client, ListOptions{Status: StatusRunning})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Name != "running" {
+		t.Errorf("summaries should be filtered by status: %v", summaries)
+	}
+
+	if _, err := ListJobs(context.Background(), client, ListOptions{Status: "Unknown"}); err == nil {
+		t.Error("unknown status should be invalid")
+	}
+}
@@ -0,0 +1,195 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	lockPrefix        = "kube-job-lock-"
+	lockDuration      = 60 * time.Second
+	lockRenewInterval = 20 * time.Second
+	lockRetryInterval = 5 * time.Second
+)
+
+// lock is a Lease which is held while the job is running.
+type lock struct {
+	j     *Job
+	name  string
+	stopc chan struct{}
+	done  chan struct{}
+}
+
+// defaultLockIdentity returns user@hostname:pid, which is recorded as a holder of the lock.
+// The process ID distinguishes the processes of the same user on the same host.
+func defaultLockIdentity() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s@%s:%d", currentUser(), host, os.Getpid())
+}
+
+// leaseIsHeld checks whether the lease is held by another holder and is not expired.
+func leaseIsHeld(lease *coordinationv1.Lease, identity string, now time.Time) bool {
+	holder := ptr.Deref(lease.Spec.HolderIdentity, "")
+	if len(holder) == 0 || holder == identity {
+		return false
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expire := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expire)
+}
+
+// acquireLock acquires the Lease in the namespace of the job.
+// If the lock is held by another holder, it waits when LockWait is true, or returns error.
+// The Lease is renewed until release is called.
+func (j *Job) acquireLock(ctx context.Context) (*lock, error) {
+	if len(j.LockIdentity) == 0 {
+		j.LockIdentity = defaultLockIdentity()
+	}
+	name := lockPrefix + j.Lock
+	for {
+		holder, err := j.tryAcquireLock(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(holder) == 0 {
+			break
+		}
+		if !j.LockWait {
+			return nil, fmt.Errorf("Lock %s is held by %s", j.Lock, holder)
+		}
+		j.logger().Warn("Lock is held by another holder, waiting...", "lock", j.Lock, "holder", holder)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+	j.logger().Info("Acquired lock", "lock", j.Lock, "identity", j.LockIdentity)
+
+	l := &lock{
+		j:     j,
+		name:  name,
+		stopc: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go l.renew(ctx)
+	return l, nil
+}
+
+// holdLock acquires the lock, and returns the function which releases it.
+func (j *Job) holdLock(ctx context.Context) (func(), error) {
+	l, err := j.acquireLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		releaseCtx, cancel := detachedContext(ctx)
+		defer cancel()
+		if err := l.release(releaseCtx); err != nil {
+			j.logger().Warn("Failed to release lock", "lock", j.Lock, "error", err)
+		}
+	}, nil
+}
+
+// tryAcquireLock creates or takes over the Lease.
+// It returns the identity of the current holder if the lock is held by another holder.
+func (j *Job) tryAcquireLock(ctx context.Context, name string) (string, error) {
+	leases := j.client.CoordinationV1().Leases(j.CurrentJob.Namespace)
+	now := metav1.NewMicroTime(time.Now())
+	spec := coordinationv1.LeaseSpec{
+		HolderIdentity:       ptr.To(j.LockIdentity),
+		LeaseDurationSeconds: ptr.To(int32(lockDuration.Seconds())),
+		AcquireTime:          &now,
+		RenewTime:            &now,
+	}
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: j.CurrentJob.Namespace,
+			},
+			Spec: spec,
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		if kerrors.IsAlreadyExists(err) {
+			return j.tryAcquireLock(ctx, name)
+		}
+		return "", err
+	}
+	if err != nil {
+		return "", err
+	}
+	if leaseIsHeld(lease, j.LockIdentity, now.Time) {
+		return *lease.Spec.HolderIdentity, nil
+	}
+	lease.Spec = spec
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if kerrors.IsConflict(err) {
+		return j.tryAcquireLock(ctx, name)
+	}
+	return "", err
+}
+
+func (l *lock) renew(ctx context.Context) {
+	defer close(l.done)
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stopc:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.update(ctx, func(lease *coordinationv1.Lease) {
+				now := metav1.NewMicroTime(time.Now())
+				lease.Spec.RenewTime = &now
+			}); err != nil {
+				l.j.logger().Warn("Failed to renew lock", "lock", l.j.Lock, "error", err)
+			}
+		}
+	}
+}
+
+// release stops renewing the Lease, and clears the holder.
+func (l *lock) release(ctx context.Context) error {
+	close(l.stopc)
+	<-l.done
+	err := l.update(ctx, func(lease *coordinationv1.Lease) {
+		lease.Spec.HolderIdentity = nil
+		lease.Spec.AcquireTime = nil
+		lease.Spec.RenewTime = nil
+	})
+	if err == nil {
+		l.j.logger().Info("Released lock", "lock", l.j.Lock)
+	}
+	return err
+}
+
+// update modifies the Lease only when this process holds it.
+func (l *lock) update(ctx context.Context, modify func(*coordinationv1.Lease)) error {
+	leases := l.j.client.CoordinationV1().Leases(l.j.CurrentJob.Namespace)
+	lease, err := leases.Get(ctx, l.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if holder := ptr.Deref(lease.Spec.HolderIdentity, ""); holder != l.j.LockIdentity {
+		return fmt.Errorf("Lock %s is held by %s", l.j.Lock, holder)
+	}
+	modify(lease)
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
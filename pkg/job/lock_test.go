@@ -0,0 +1,183 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1coordination "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/utils/ptr"
+)
+
+type mockedCoordinationV1 struct {
+	coordinationv1.CoordinationV1Interface
+	mockedLease *mockedLease
+}
+
+type mockedLease struct {
+	coordinationv1.LeaseInterface
+	lease *v1coordination.Lease
+}
+
+func (m mockedCoordinationV1) Leases(namespace string) coordinationv1.LeaseInterface {
+	return m.mockedLease
+}
+
+func (m *mockedLease) Get(ctx context.Context, name string, options metav1.GetOptions) (*v1coordination.Lease, error) {
+	if m.lease == nil {
+		return nil, kerrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, name)
+	}
+	return m.lease.DeepCopy(), nil
+}
+
+func (m *mockedLease) Create(ctx context.Context, lease *v1coordination.Lease, options metav1.CreateOptions) (*v1coordination.Lease, error) {
+	m.lease = lease.DeepCopy()
+	return lease, nil
+}
+
+func (m *mockedLease) Update(ctx context.Context, lease *v1coordination.Lease, options metav1.UpdateOptions) (*v1coordination.Lease, error) {
+	m.lease = lease.DeepCopy()
+	return lease, nil
+}
+
+func TestLeaseIsHeld(t *testing.T) {
+	now := time.Now()
+	renewed := metav1.NewMicroTime(now.Add(-30 * time.Second))
+	lease := &v1coordination.Lease{
+		Spec: v1coordination.LeaseSpec{
+			HolderIdentity:       ptr.To("alice@host"),
+			LeaseDurationSeconds: ptr.To(int32(60)),
+			RenewTime:            &renewed,
+		},
+	}
+	if !leaseIsHeld(lease, "bob@host", now) {
+		t.Error("lease should be held by another holder")
+	}
+	if leaseIsHeld(lease, "alice@host", now) {
+		t.Error("lease should not be held by the same holder")
+	}
+	if leaseIsHeld(lease, "bob@host", now.Add(time.Minute)) {
+		t.Error("expired lease should not be held")
+	}
+	lease.Spec.HolderIdentity = nil
+	if leaseIsHeld(lease, "bob@host", now) {
+		t.Error("released lease should not be held")
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	leaseMock := &mockedLease{}
+	client := mockedKubernetes{
+		mockedCoordination: mockedCoordinationV1{
+			mockedLease: leaseMock,
+		},
+	}
+	alice := &Job{
+		CurrentJob:   currentJob,
+		Lock:         "backfill",
+		LockIdentity: "alice@host",
+		client:       client,
+	}
+	bob := &Job{
+		CurrentJob:   currentJob,
+		Lock:         "backfill",
+		LockIdentity: "bob@host",
+		client:       client,
+	}
+
+	ctx := context.Background()
+	l, err := alice.acquireLock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaseMock.lease.Name != "kube-job-lock-backfill" || *leaseMock.lease.Spec.HolderIdentity != "alice@host" {
+		t.Errorf("lease does not match: %v", leaseMock.lease)
+	}
+	if _, err := bob.acquireLock(ctx); err == nil {
+		t.Error("lock should be held by alice")
+	}
+
+	if err := l.release(ctx); err != nil {
+		t.Error(err)
+	}
+	l, err = bob.acquireLock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *leaseMock.lease.Spec.HolderIdentity != "bob@host" {
+		t.Errorf("lease should be held by bob: %v", leaseMock.lease)
+	}
+	if err := l.release(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLockWithoutFollow(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{
+		CurrentJob: currentJob,
+		Lock:       "backfill",
+	}
+	if err := job.RunAndCleanup(context.Background(), All.String(), false, false); err == nil {
+		t.Error("lock without following the job should be error")
+	}
+	results := job.RunMatrix(context.Background(), []Parameters{{"tenant": "a"}, {"tenant": "b"}}, 2, All.String(), false, false)
+	for _, result := range results {
+		if result.Err == nil {
+			t.Errorf("lock without following the job should be error: %v", result)
+		}
+	}
+}
+
+func TestMatrixLock(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaseMock := &mockedLease{}
+	client := mockedKubernetes{
+		mockedCoordination: mockedCoordinationV1{
+			mockedLease: leaseMock,
+		},
+	}
+	bob := &Job{
+		CurrentJob:   currentJob,
+		Lock:         "backfill",
+		LockIdentity: "bob@host",
+		client:       client,
+	}
+	ctx := context.Background()
+	l, err := bob.acquireLock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.release(ctx)
+
+	alice := &Job{
+		CurrentJob:    currentJob,
+		Lock:          "backfill",
+		LockIdentity:  "alice@host",
+		SkipPreflight: true,
+		client:        client,
+	}
+	results := alice.RunMatrix(ctx, []Parameters{{"tenant": "a"}, {"tenant": "b"}}, 2, All.String(), false, true)
+	for _, result := range results {
+		if result.Err == nil || len(result.JobName) > 0 {
+			t.Errorf("jobs should not run while the lock is held by bob: %v", result)
+		}
+	}
+	if child := alice.withParameters(Parameters{"tenant": "a"}, nil); len(child.Lock) > 0 {
+		t.Error("jobs in the matrix should not acquire the lock")
+	}
+}
@@ -0,0 +1,22 @@
+package job
+
+import "log/slog"
+
+// discardLogger is used when no logger is set, so the library does not write logs by default.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// loggerOrDiscard returns the logger, or discardLogger if it is nil.
+func loggerOrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discardLogger
+	}
+	return logger
+}
+
+func (j *Job) logger() *slog.Logger {
+	return loggerOrDiscard(j.Logger)
+}
+
+func (w *Watcher) logger() *slog.Logger {
+	return loggerOrDiscard(w.Logger)
+}
@@ -0,0 +1,263 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Parameters is a parameter set of a matrix.
+// ${KEY} in the args and the env values of the target container is replaced with the value.
+type Parameters map[string]string
+
+// String returns the parameters as KEY=VALUE, which is sorted by the keys.
+func (p Parameters) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+p[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (p Parameters) replace(s string) string {
+	for k, v := range p {
+		s = strings.ReplaceAll(s, "${"+k+"}", v)
+	}
+	return s
+}
+
+// substituteParameters replaces placeholders in the args and the env values of the container.
+func substituteParameters(container *corev1.Container, params Parameters) {
+	// Args may be shared with the other jobs in the matrix, so they are copied before replacing.
+	args := make([]string, len(container.Args))
+	for i, arg := range container.Args {
+		args[i] = params.replace(arg)
+	}
+	container.Args = args
+	for i, env := range container.Env {
+		container.Env[i].Value = params.replace(env.Value)
+	}
+}
+
+// LoadMatrix reads a matrix file, which is a list of parameter sets.
+//
+//   - tenant: a
+//     region: us
+//   - tenant: b
+//     region: eu
+func LoadMatrix(file string) ([]Parameters, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	// Values may be numbers or booleans in YAML, so they are converted to strings.
+	var sets []map[string]interface{}
+	if err := yaml.Unmarshal(data, &sets); err != nil {
+		return nil, err
+	}
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("Matrix file %s does not have any parameter sets", file)
+	}
+	matrix := make([]Parameters, 0, len(sets))
+	for _, set := range sets {
+		params := Parameters{}
+		for k, v := range set {
+			params[k] = parameterValue(v)
+		}
+		matrix = append(matrix, params)
+	}
+	return matrix, nil
+}
+
+// parameterValue converts a value in the matrix file to a string.
+func parameterValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		// Numbers are decoded as float64, so format them without the exponent, like 1000000.
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// ParseForEach parses KEY=VALUE1,VALUE2,... definitions, and returns the cartesian product of them.
+func ParseForEach(values []string) ([]Parameters, error) {
+	matrix := []Parameters{{}}
+	for _, value := range values {
+		key, list, found := strings.Cut(value, "=")
+		if !found || len(key) == 0 || len(list) == 0 {
+			return nil, fmt.Errorf("Invalid for-each %q, it must be KEY=VALUE1,VALUE2", value)
+		}
+		product := []Parameters{}
+		for _, params := range matrix {
+			for _, v := range strings.Split(list, ",") {
+				p := Parameters{key: v}
+				for k, v := range params {
+					p[k] = v
+				}
+				product = append(product, p)
+			}
+		}
+		matrix = product
+	}
+	return matrix, nil
+}
+
+// MatrixResult is a result of a job in the matrix.
+type MatrixResult struct {
+	Parameters Parameters
+	// Name of the created job.
+	JobName string
+	Err     error
+}
+
+// RunMatrix runs a job for each parameter set, with at most maxParallel jobs at the same time.
+// Logs of each job are prefixed with the parameters.
+// The results are returned in the same order as the matrix.
+// When ctx is canceled, jobs which are not started yet are not run.
+// When Lock is set, the lock is held by the matrix until all jobs are finished, so the jobs do not contend with each other.
+func (j *Job) RunMatrix(ctx context.Context, matrix []Parameters, maxParallel int, cleanupType string, ignoreSidecar bool, followLogs bool) []MatrixResult {
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+	results := make([]MatrixResult, len(matrix))
+	fail := func(err error) []MatrixResult {
+		for i, params := range matrix {
+			results[i] = MatrixResult{Parameters: params, Err: err}
+		}
+		return results
+	}
+	if len(j.Lock) > 0 && !followLogs {
+		return fail(errors.New("Lock can not be held without following the job"))
+	}
+	if err := j.validate(); err != nil {
+		return fail(err)
+	}
+	// Permissions are checked once for all jobs in the matrix.
+	if err := j.Preflight(ctx, followLogs); err != nil {
+		return fail(err)
+	}
+	if len(j.Lock) > 0 {
+		release, err := j.holdLock(ctx)
+		if err != nil {
+			return fail(err)
+		}
+		defer release()
+	}
+	output := j.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	if len(j.ResolveDigest) > 0 {
+		// Create the registry client before copying the job, so all jobs share the resolved digests.
+		j.registryClient()
+	}
+	var mu sync.Mutex
+	semaphore := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+	for i, params := range matrix {
+		wg.Add(1)
+		go func(i int, params Parameters) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = MatrixResult{Parameters: params, Err: ctx.Err()}
+				return
+			}
+			defer func() { <-semaphore }()
+
+			writer := newPrefixWriter(output, &mu, "["+params.String()+"] ")
+			child := j.withParameters(params, writer)
+			j.logger().Info("Starting job for parameters", "job", child.CurrentJob.Name, "parameters", params.String())
+			err := child.RunAndCleanup(ctx, cleanupType, ignoreSidecar, followLogs)
+			writer.Flush()
+			results[i] = MatrixResult{
+				Parameters: params,
+				JobName:    child.CurrentJob.Name,
+				Err:        err,
+			}
+		}(i, params)
+	}
+	wg.Wait()
+	return results
+}
+
+// withParameters returns a copy of the job which has a new name and the parameters.
+// The copy does not acquire the lock and check the permissions, because they are done by the matrix.
+func (j *Job) withParameters(params Parameters, output io.Writer) *Job {
+	child := *j
+	child.CurrentJob = j.CurrentJob.DeepCopy()
+	child.CurrentJob.SetName(generateRandomName(j.baseName()))
+	child.Parameters = params
+	child.Output = output
+	child.Lock = ""
+	child.SkipPreflight = true
+	if len(j.IdempotencyKey) > 0 {
+		// Each parameter set is a different job, so it must not be a duplicate of the others.
+		child.IdempotencyKey = j.IdempotencyKey + "/" + params.String()
+	}
+	return &child
+}
+
+// prefixWriter writes each line with the prefix.
+// Lines are written with the shared lock, so lines of parallel jobs are not mixed.
+type prefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, mu *sync.Mutex, prefix string) *prefixWriter {
+	return &prefixWriter{
+		w:      w,
+		mu:     mu,
+		prefix: prefix,
+	}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := io.WriteString(p.w, p.prefix+string(p.buf[:i+1])); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes the remaining line which does not end with a newline.
+func (p *prefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(p.w, p.prefix+string(p.buf)+"\n")
+	p.buf = nil
+	return err
+}
@@ -0,0 +1,112 @@
+package job
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	v1core "k8s.io/api/core/v1"
+)
+
+func TestParametersString(t *testing.T) {
+	params := Parameters{
+		"tenant": "a",
+		"region": "us",
+	}
+	if params.String() != "region=us,tenant=a" {
+		t.Errorf("parameters string does not match: %s", params.String())
+	}
+}
+
+func TestSubstituteParameters(t *testing.T) {
+	args := []string{"migrate", "--tenant=${tenant}"}
+	container := v1core.Container{
+		Args: args,
+		Env: []v1core.EnvVar{
+			{
+				Name:  "TENANT",
+				Value: "${tenant}",
+			},
+			{
+				Name:  "REGION",
+				Value: "${region}",
+			},
+		},
+	}
+	substituteParameters(&container, Parameters{"tenant": "a"})
+	if container.Args[1] != "--tenant=a" {
+		t.Errorf("args are not substituted: %v", container.Args)
+	}
+	if args[1] != "--tenant=${tenant}" {
+		t.Error("original args should not be changed")
+	}
+	if container.Env[0].Value != "a" {
+		t.Errorf("env is not substituted: %v", container.Env)
+	}
+	if container.Env[1].Value != "${region}" {
+		t.Errorf("unknown parameter should not be substituted: %v", container.Env)
+	}
+}
+
+func TestLoadMatrix(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "matrix.yaml")
+	content := "- tenant: a\n  region: us\n- tenant: b\n  region: eu\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	matrix, err := LoadMatrix(file)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(matrix) != 2 || matrix[0]["tenant"] != "a" || matrix[1]["region"] != "eu" {
+		t.Errorf("matrix does not match: %v", matrix)
+	}
+}
+
+func TestLoadMatrixWithNumbers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "matrix.yaml")
+	content := "- tenant: 1\n  shard: 1000000\n  ratio: 0.5\n  dry_run: true\n- tenant: 2\n  shard: 2000000\n  ratio: 1.5\n  dry_run: false\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	matrix, err := LoadMatrix(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Parameters{"tenant": "1", "shard": "1000000", "ratio": "0.5", "dry_run": "true"}
+	if len(matrix) != 2 || matrix[0].String() != expected.String() || matrix[1]["dry_run"] != "false" {
+		t.Errorf("matrix does not match: %v", matrix)
+	}
+}
+
+func TestParseForEach(t *testing.T) {
+	matrix, err := ParseForEach([]string{"tenant=a,b,c", "region=us,eu"})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(matrix) != 6 {
+		t.Errorf("matrix should be cartesian product: %v", matrix)
+	}
+	if matrix[0].String() != "region=us,tenant=a" || matrix[5].String() != "region=eu,tenant=c" {
+		t.Errorf("matrix does not match: %v", matrix)
+	}
+
+	if _, err := ParseForEach([]string{"tenant"}); err == nil {
+		t.Error("for-each without values should be invalid")
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	writer := newPrefixWriter(&out, &mu, "[tenant=a] ")
+	writer.Write([]byte("hoge\nfu"))
+	writer.Write([]byte("ga\npiyo"))
+	writer.Flush()
+	expected := "[tenant=a] hoge\n[tenant=a] fuga\n[tenant=a] piyo\n"
+	if out.String() != expected {
+		t.Errorf("output does not match: %q", out.String())
+	}
+}
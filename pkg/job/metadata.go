@@ -0,0 +1,107 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Version is recorded in the annotation of the jobs. It is set by the command line tool.
+var Version = "unknown"
+
+// reservedPodLabels are managed by the job controller, so they can not be overridden.
+var reservedPodLabels = []string{"controller-uid", "job-name", "batch.kubernetes.io/"}
+
+// ParseKeyValues parses KEY=VALUE definitions.
+func ParseKeyValues(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	result := map[string]string{}
+	for _, value := range values {
+		k, v, found := strings.Cut(value, "=")
+		if !found || len(k) == 0 {
+			return nil, fmt.Errorf("Invalid %q, it must be KEY=VALUE", value)
+		}
+		result[k] = v
+	}
+	return result, nil
+}
+
+// validateMetadata checks labels and annotations overrides, and returns all errors together.
+// Pod labels must not break the selector of the job.
+func (j *Job) validateMetadata() error {
+	errs := []string{}
+	validateLabels := func(kind string, labels map[string]string) {
+		for k, v := range labels {
+			for _, msg := range validation.IsQualifiedName(k) {
+				errs = append(errs, fmt.Sprintf("%s key %q: %s", kind, k, msg))
+			}
+			for _, msg := range validation.IsValidLabelValue(v) {
+				errs = append(errs, fmt.Sprintf("%s value %q: %s", kind, v, msg))
+			}
+		}
+	}
+	validateAnnotations := func(kind string, annotations map[string]string) {
+		for k := range annotations {
+			for _, msg := range validation.IsQualifiedName(k) {
+				errs = append(errs, fmt.Sprintf("%s key %q: %s", kind, k, msg))
+			}
+		}
+	}
+	validateLabels("label", j.Labels)
+	validateLabels("pod label", j.PodLabels)
+	validateAnnotations("annotation", j.Annotations)
+	validateAnnotations("pod annotation", j.PodAnnotations)
+
+	for k := range j.Labels {
+		if k == ManagedByLabel || strings.HasPrefix(k, "kube-job/") {
+			errs = append(errs, fmt.Sprintf("label key %q: it is managed by kube-job", k))
+		}
+	}
+	for k, v := range j.PodLabels {
+		for _, reserved := range reservedPodLabels {
+			if k == reserved || (strings.HasSuffix(reserved, "/") && strings.HasPrefix(k, reserved)) {
+				errs = append(errs, fmt.Sprintf("pod label key %q: it is managed by the job controller", k))
+			}
+		}
+		if selector := j.CurrentJob.Spec.Selector; selector != nil {
+			if current, ok := selector.MatchLabels[k]; ok && current != v {
+				errs = append(errs, fmt.Sprintf("pod label key %q: it breaks the selector of the job", k))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Invalid metadata overrides: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// applyMetadata adds labels and annotations to the job and the pod template.
+// It also records the version of kube-job, the user and the template source in the annotations of the job.
+func (j *Job) applyMetadata(job *v1.Job) {
+	job.Labels = mergeMap(job.Labels, j.Labels)
+	job.Annotations = mergeMap(job.Annotations, j.Annotations)
+	job.Spec.Template.Labels = mergeMap(job.Spec.Template.Labels, j.PodLabels)
+	job.Spec.Template.Annotations = mergeMap(job.Spec.Template.Annotations, j.PodAnnotations)
+	job.Annotations = mergeMap(job.Annotations, map[string]string{
+		VersionAnnotation:        Version,
+		UserAnnotation:           currentUser(),
+		TemplateSourceAnnotation: j.templateSource,
+	})
+}
+
+func mergeMap(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = map[string]string{}
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,91 @@
+package job
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	values, err := ParseKeyValues([]string{"team=platform", "git-sha=abc123", "empty="})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(values) != 3 || values["team"] != "platform" || values["empty"] != "" {
+		t.Errorf("values do not match: %v", values)
+	}
+	if _, err := ParseKeyValues([]string{"=platform"}); err == nil {
+		t.Error("empty key should be invalid")
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	currentJob.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"app": "example"},
+	}
+	job := &Job{
+		CurrentJob:     currentJob,
+		Labels:         map[string]string{"team": "platform"},
+		Annotations:    map[string]string{"example.com/trace-id": "not a label value"},
+		PodLabels:      map[string]string{"app": "example", "ci-run-id": "1234"},
+		PodAnnotations: map[string]string{"git-sha": "abc123"},
+	}
+	if err := job.validateMetadata(); err != nil {
+		t.Error(err)
+	}
+
+	invalids := map[string]*Job{
+		"invalid label value":  {CurrentJob: currentJob, Labels: map[string]string{"team": "platform team"}},
+		"managed label":        {CurrentJob: currentJob, Labels: map[string]string{"kube-job/run-id": "1234"}},
+		"controller pod label": {CurrentJob: currentJob, PodLabels: map[string]string{"batch.kubernetes.io/job-name": "hoge"}},
+		"selector pod label":   {CurrentJob: currentJob, PodLabels: map[string]string{"app": "another"}},
+	}
+	for name, job := range invalids {
+		if err := job.validateMetadata(); err == nil {
+			t.Errorf("%s should be invalid", name)
+		}
+	}
+}
+
+func TestRunJobWithMetadata(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	job := &Job{
+		CurrentJob:     currentJob,
+		Container:      "alpine",
+		Labels:         map[string]string{"team": "platform"},
+		Annotations:    map[string]string{"ci-run-id": "1234"},
+		PodLabels:      map[string]string{"team": "platform"},
+		PodAnnotations: map[string]string{"git-sha": "abc123"},
+		templateSource: "../../example/job.yaml",
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: mockedJob{},
+			},
+		},
+	}
+
+	j, err := job.RunJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Labels["team"] != "platform" || j.Labels["app"] != "example-job" || j.Labels[ManagedByLabel] != ManagedByValue {
+		t.Errorf("labels do not match: %v", j.Labels)
+	}
+	if j.Annotations["ci-run-id"] != "1234" || j.Annotations[TemplateSourceAnnotation] != "../../example/job.yaml" || j.Annotations[VersionAnnotation] != Version {
+		t.Errorf("annotations do not match: %v", j.Annotations)
+	}
+	if j.Spec.Template.Labels["team"] != "platform" || j.Spec.Template.Labels["app"] != "example" {
+		t.Errorf("pod labels do not match: %v", j.Spec.Template.Labels)
+	}
+	if j.Spec.Template.Annotations["git-sha"] != "abc123" {
+		t.Errorf("pod annotations do not match: %v", j.Spec.Template.Annotations)
+	}
+}
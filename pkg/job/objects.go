@@ -0,0 +1,130 @@
+package job
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hasObjects returns whether the job needs ConfigMaps or Secrets which are created with the job,
+// for the files and the secret env.
+func (j *Job) hasObjects() bool {
+	return len(j.Files) > 0 || len(j.SecretEnv) > 0
+}
+
+func (j *Job) objectMeta(job *v1.Job, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: job.Namespace,
+		Labels:    j.managedLabels(job.Labels[ContainerLabel]),
+	}
+}
+
+// objects returns the ConfigMaps and the Secrets which are created with the job.
+func (j *Job) objects(job *v1.Job) ([]*corev1.ConfigMap, []*corev1.Secret) {
+	configMaps := []*corev1.ConfigMap{}
+	if configMap := j.filesConfigMap(job); configMap != nil {
+		configMaps = append(configMaps, configMap)
+	}
+	secrets := []*corev1.Secret{}
+	for _, secret := range []*corev1.Secret{j.filesSecret(job), j.secretEnvSecret(job)} {
+		if secret != nil {
+			secrets = append(secrets, secret)
+		}
+	}
+	return configMaps, secrets
+}
+
+// createObjects creates the ConfigMaps and the Secrets of the job. Usually they are created before the job,
+// so the pod can use them when it starts. When owned is set, the job is already created and it is set as the owner.
+func (j *Job) createObjects(ctx context.Context, job *v1.Job, owned bool) error {
+	configMaps, secrets := j.objects(job)
+	for _, configMap := range configMaps {
+		if owned {
+			configMap.OwnerReferences = []metav1.OwnerReference{jobOwner(job)}
+		}
+		if _, err := j.client.CoreV1().ConfigMaps(job.Namespace).Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+			return errors.Wrapf(err, "Failed to create ConfigMap %s", configMap.Name)
+		}
+	}
+	for _, secret := range secrets {
+		if owned {
+			secret.OwnerReferences = []metav1.OwnerReference{jobOwner(job)}
+		}
+		if _, err := j.client.CoreV1().Secrets(job.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return errors.Wrapf(err, "Failed to create Secret %s", secret.Name)
+		}
+	}
+	return nil
+}
+
+func jobOwner(job *v1.Job) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Name:       job.Name,
+		UID:        job.UID,
+	}
+}
+
+// ownObjects sets the job as the owner of the ConfigMaps and the Secrets, so they are removed by the garbage collector
+// even if kube-job can not clean up them.
+func (j *Job) ownObjects(ctx context.Context, job *v1.Job) error {
+	owner := jobOwner(job)
+	configMaps, secrets := j.objects(job)
+	for _, c := range configMaps {
+		client := j.client.CoreV1().ConfigMaps(job.Namespace)
+		configMap, err := client.Get(ctx, c.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		configMap.OwnerReferences = append(configMap.OwnerReferences, owner)
+		if _, err := client.Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	for _, s := range secrets {
+		client := j.client.CoreV1().Secrets(job.Namespace)
+		secret, err := client.Get(ctx, s.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		secret.OwnerReferences = append(secret.OwnerReferences, owner)
+		if _, err := client.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeObjects removes the ConfigMaps and the Secrets of the job. They may be already removed with the job.
+func (j *Job) removeObjects(ctx context.Context, job *v1.Job) error {
+	configMaps, secrets := j.objects(job)
+	for _, configMap := range configMaps {
+		err := j.client.CoreV1().ConfigMaps(job.Namespace).Delete(ctx, configMap.Name, metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+	for _, secret := range secrets {
+		err := j.client.CoreV1().Secrets(job.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeObjectsDetached removes the objects on a context which is not canceled with ctx.
+// The objects have no owner yet, so they would remain if they are not removed here.
+func (j *Job) removeObjectsDetached(ctx context.Context, job *v1.Job) {
+	removeCtx, cancel := detachedContext(ctx)
+	defer cancel()
+	if err := j.removeObjects(removeCtx, job); err != nil {
+		j.logger().Warn("Failed to remove the objects of the job", "job", job.Name, "error", err)
+	}
+}
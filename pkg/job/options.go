@@ -0,0 +1,439 @@
+package job
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	shellwords "github.com/mattn/go-shellwords"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Option configures a Job which is created by New.
+type Option func(*options) error
+
+type options struct {
+	kubeconfig  KubeconfigOptions
+	client      kubernetes.Interface
+	restConfig  *rest.Config
+	template    string
+	templateJob *v1.Job
+	job         Job
+}
+
+// WithKubeconfig sets the path of the kubeconfig file. Colon-separated paths are merged like KUBECONFIG.
+// If neither it nor WithClient is set, the default kubeconfig or in-cluster config is used.
+func WithKubeconfig(path string) Option {
+	return func(o *options) error {
+		o.kubeconfig.Path = path
+		return nil
+	}
+}
+
+// WithKubeconfigOptions sets the kubeconfig file, and the context, the cluster and the user to use.
+// If the template does not have a namespace, the namespace of the context is used.
+func WithKubeconfigOptions(kubeconfig KubeconfigOptions) Option {
+	return func(o *options) error {
+		o.kubeconfig = kubeconfig
+		return nil
+	}
+}
+
+// WithClient sets the kubernetes client, instead of creating it from the kubeconfig.
+func WithClient(client kubernetes.Interface) Option {
+	return func(o *options) error {
+		o.client = client
+		return nil
+	}
+}
+
+// WithRestConfig sets the rest config which is used to execute commands in the containers.
+// It is necessary with WithClient only if you use exec in SidecarShutdown.
+func WithRestConfig(config *rest.Config) Option {
+	return func(o *options) error {
+		o.restConfig = config
+		return nil
+	}
+}
+
+// WithTemplate sets the path or URL of the job template yaml file.
+func WithTemplate(template string) Option {
+	return func(o *options) error {
+		o.template = template
+		return nil
+	}
+}
+
+// WithTemplateJob sets the job template, instead of reading it from a file.
+func WithTemplateJob(job *v1.Job) Option {
+	return func(o *options) error {
+		o.templateJob = job.DeepCopy()
+		return nil
+	}
+}
+
+// WithName sets the name of the job. Random string is added to the name.
+func WithName(name string) Option {
+	return func(o *options) error {
+		o.job.Name = name
+		return nil
+	}
+}
+
+// WithArgs sets the args which override the target container.
+func WithArgs(args ...string) Option {
+	return func(o *options) error {
+		o.job.Args = args
+		return nil
+	}
+}
+
+// WithArgsString parses the command line like a shell, and sets it as the args.
+func WithArgsString(command string) Option {
+	return func(o *options) error {
+		p := shellwords.NewParser()
+		args, err := p.Parse(command)
+		if err != nil {
+			return err
+		}
+		o.job.Args = args
+		return nil
+	}
+}
+
+// WithImage sets the image which overrides the target container.
+func WithImage(image string) Option {
+	return func(o *options) error {
+		o.job.Image = image
+		return nil
+	}
+}
+
+// WithResources sets the resources which override the target container.
+func WithResources(resources corev1.ResourceRequirements) Option {
+	return func(o *options) error {
+		o.job.Resources = resources
+		return nil
+	}
+}
+
+// WithNamespace sets the namespace which overrides the job template.
+func WithNamespace(namespace string) Option {
+	return func(o *options) error {
+		o.job.Namespace = namespace
+		return nil
+	}
+}
+
+// WithContainer sets the target container name.
+func WithContainer(container string) Option {
+	return func(o *options) error {
+		o.job.Container = container
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout to wait the job. If you set 0, timeout is ignored.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		o.job.Timeout = timeout
+		return nil
+	}
+}
+
+// WithOutput sets the writer which the logs of the pods are written to.
+func WithOutput(output io.Writer) Option {
+	return func(o *options) error {
+		o.job.Output = output
+		return nil
+	}
+}
+
+// WithLogger sets the logger to write diagnostic messages. By default, nothing is written.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) error {
+		o.job.Logger = logger
+		return nil
+	}
+}
+
+// WithEventHandler sets the handler which receives lifecycle events of the job.
+func WithEventHandler(handler EventHandler) Option {
+	return func(o *options) error {
+		o.job.Events = handler
+		return nil
+	}
+}
+
+// WithSkipPreflight disables the permission check before running the job.
+func WithSkipPreflight(skip bool) Option {
+	return func(o *options) error {
+		o.job.SkipPreflight = skip
+		return nil
+	}
+}
+
+// WithPolicy sets the policy which the job must satisfy before it is created.
+// If override is true, violations are recorded in the annotation instead of failing.
+func WithPolicy(policy *Policy, override bool) Option {
+	return func(o *options) error {
+		o.job.Policy = policy
+		o.job.OverridePolicy = override
+		return nil
+	}
+}
+
+// WithSidecarShutdowns sets sidecar containers which are stopped after the target container is terminated.
+func WithSidecarShutdowns(sidecars ...SidecarShutdown) Option {
+	return func(o *options) error {
+		o.job.SidecarShutdowns = sidecars
+		return nil
+	}
+}
+
+// WithEnv sets environment variables which override the target container.
+func WithEnv(env map[string]string) Option {
+	return func(o *options) error {
+		o.job.Env = env
+		return nil
+	}
+}
+
+// WithSecretEnv sets environment variables of the target container which are stored in a Secret created with the job.
+func WithSecretEnv(env map[string]string) Option {
+	return func(o *options) error {
+		if err := validateSecretEnv(env); err != nil {
+			return err
+		}
+		o.job.SecretEnv = env
+		return nil
+	}
+}
+
+// WithArtifacts sets files or directories which are copied from the target container after it is terminated,
+// and the image of the helper container. If the image is empty, DefaultArtifactImage is used.
+func WithArtifacts(artifacts []Artifact, image string) Option {
+	return func(o *options) error {
+		if err := validateArtifacts(artifacts); err != nil {
+			return err
+		}
+		o.job.Artifacts = artifacts
+		o.job.ArtifactImage = image
+		return nil
+	}
+}
+
+// WithFiles sets files which are mounted into the target container.
+func WithFiles(files []MountedFile) Option {
+	return func(o *options) error {
+		if err := validateFiles(files); err != nil {
+			return err
+		}
+		o.job.Files = files
+		return nil
+	}
+}
+
+// WithParameters sets parameters which are substituted into the args and the env values of the target container.
+func WithParameters(params Parameters) Option {
+	return func(o *options) error {
+		o.job.Parameters = params
+		return nil
+	}
+}
+
+// WithRetry sets the policy to run a new job when the job is failed by transient failures.
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *options) error {
+		o.job.Retry = policy
+		return nil
+	}
+}
+
+// WithIdempotencyKey sets the key to prevent duplicate runs, and what to do when a duplicate exists.
+func WithIdempotencyKey(key, onDuplicate string) Option {
+	return func(o *options) error {
+		if err := validateOnDuplicate(onDuplicate); err != nil {
+			return err
+		}
+		o.job.IdempotencyKey = key
+		o.job.OnDuplicate = onDuplicate
+		return nil
+	}
+}
+
+// WithResolveDigest sets which images are pinned to the digests before creating the job: container or all.
+func WithResolveDigest(mode string) Option {
+	return func(o *options) error {
+		if err := validateResolveDigest(mode); err != nil {
+			return err
+		}
+		o.job.ResolveDigest = mode
+		return nil
+	}
+}
+
+// WithLock sets the name of the lock which is acquired before running the job.
+func WithLock(name string, wait bool) Option {
+	return func(o *options) error {
+		o.job.Lock = name
+		o.job.LockWait = wait
+		return nil
+	}
+}
+
+// WithTTLAfterFinished sets seconds to remove the job by Kubernetes after the job is finished.
+func WithTTLAfterFinished(seconds int32) Option {
+	return func(o *options) error {
+		o.job.TTLAfterFinished = &seconds
+		return nil
+	}
+}
+
+// WithNodeSelector sets the node selector which is merged into the pod template.
+func WithNodeSelector(selector map[string]string) Option {
+	return func(o *options) error {
+		o.job.NodeSelector = selector
+		return nil
+	}
+}
+
+// WithTolerations sets tolerations which are added to the pod template.
+func WithTolerations(tolerations ...corev1.Toleration) Option {
+	return func(o *options) error {
+		o.job.Tolerations = tolerations
+		return nil
+	}
+}
+
+// WithAffinity sets the affinity which overrides the pod template.
+func WithAffinity(affinity *corev1.Affinity) Option {
+	return func(o *options) error {
+		o.job.Affinity = affinity
+		return nil
+	}
+}
+
+// WithPriorityClass sets the priority class name which overrides the pod template.
+func WithPriorityClass(name string) Option {
+	return func(o *options) error {
+		o.job.PriorityClassName = name
+		return nil
+	}
+}
+
+// WithServiceAccount sets the service account name which overrides the pod template.
+func WithServiceAccount(name string) Option {
+	return func(o *options) error {
+		o.job.ServiceAccountName = name
+		return nil
+	}
+}
+
+// WithActiveDeadline sets active deadline seconds which overrides the job spec.
+func WithActiveDeadline(seconds int64) Option {
+	return func(o *options) error {
+		o.job.ActiveDeadlineSeconds = &seconds
+		return nil
+	}
+}
+
+// WithBackoffLimit sets the backoff limit which overrides the job spec.
+func WithBackoffLimit(limit int32) Option {
+	return func(o *options) error {
+		o.job.BackoffLimit = &limit
+		return nil
+	}
+}
+
+// WithLabels sets labels which are added to the job.
+func WithLabels(labels map[string]string) Option {
+	return func(o *options) error {
+		o.job.Labels = labels
+		return nil
+	}
+}
+
+// WithAnnotations sets annotations which are added to the job.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(o *options) error {
+		o.job.Annotations = annotations
+		return nil
+	}
+}
+
+// WithPodLabels sets labels which are added to the pod template.
+func WithPodLabels(labels map[string]string) Option {
+	return func(o *options) error {
+		o.job.PodLabels = labels
+		return nil
+	}
+}
+
+// WithPodAnnotations sets annotations which are added to the pod template.
+func WithPodAnnotations(annotations map[string]string) Option {
+	return func(o *options) error {
+		o.job.PodAnnotations = annotations
+		return nil
+	}
+}
+
+// New returns a new Job struct which is configured with the options.
+// The job template is required, with WithTemplate or WithTemplateJob.
+func New(ctx context.Context, opts ...Option) (*Job, error) {
+	o := &options{}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.template) == 0 && o.templateJob == nil {
+		return nil, errors.New("Template file is required")
+	}
+
+	j := o.job
+	j.client = o.client
+	j.restConfig = o.restConfig
+	defaultNamespace := ""
+	if j.client == nil {
+		client, restConfig, namespace, err := newClient(o.kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		j.client = client
+		j.restConfig = restConfig
+		defaultNamespace = namespace
+	}
+
+	currentJob := o.templateJob
+	if currentJob == nil {
+		var err error
+		currentJob, err = readTemplate(ctx, o.template)
+		if err != nil {
+			return nil, err
+		}
+		j.templateSource = o.template
+	}
+	j.templateName = currentJob.Name
+	if len(j.Name) > 0 {
+		j.templateName = j.Name
+	}
+	currentJob.SetName(generateRandomName(j.templateName))
+	if len(j.Namespace) > 0 {
+		currentJob.SetNamespace(j.Namespace)
+	} else if len(currentJob.Namespace) == 0 {
+		currentJob.SetNamespace(defaultNamespace)
+	}
+	j.CurrentJob = currentJob
+	if len(j.RunID) == 0 {
+		j.RunID = secureRandomStr(8)
+	}
+
+	j.logger().Info("Received args", "args", j.Args)
+	return &j, nil
+}
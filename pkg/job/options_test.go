@@ -0,0 +1,100 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/batch/v1"
+	v1core "k8s.io/api/core/v1"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	client := mockedKubernetes{}
+	j, err := New(
+		context.Background(),
+		WithClient(client),
+		WithTemplate("../../example/job.yaml"),
+		WithName("migrate"),
+		WithArgs("echo", "hoge"),
+		WithImage("alpine:3"),
+		WithNamespace("batch"),
+		WithContainer("alpine"),
+		WithTimeout(10*time.Minute),
+		WithTolerations(v1core.Toleration{Key: "dedicated", Operator: v1core.TolerationOpExists}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.client != client {
+		t.Error("client should be injected")
+	}
+	if !strings.HasPrefix(j.CurrentJob.Name, "migrate-") || j.CurrentJob.Namespace != "batch" {
+		t.Errorf("job metadata does not match: %v", j.CurrentJob.ObjectMeta)
+	}
+	if !reflect.DeepEqual(j.Args, []string{"echo", "hoge"}) || j.Image != "alpine:3" || j.Container != "alpine" || j.Timeout != 10*time.Minute {
+		t.Errorf("job does not match: %v", j)
+	}
+	if len(j.Tolerations) != 1 || j.templateSource != "../../example/job.yaml" || len(j.RunID) == 0 {
+		t.Errorf("job does not match: %v", j)
+	}
+}
+
+func TestNewWithTemplateJob(t *testing.T) {
+	template := &v1.Job{}
+	template.Name = "example-job"
+	j, err := New(
+		context.Background(),
+		WithClient(mockedKubernetes{}),
+		WithTemplateJob(template),
+		WithArgsString(`echo "hoge fuga"`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(j.CurrentJob.Name, "example-job-") || template.Name != "example-job" {
+		t.Errorf("job name does not match: %s", j.CurrentJob.Name)
+	}
+	if !reflect.DeepEqual(j.Args, []string{"echo", "hoge fuga"}) {
+		t.Errorf("args do not match: %v", j.Args)
+	}
+}
+
+func TestNewWithoutTemplate(t *testing.T) {
+	if _, err := New(context.Background(), WithClient(mockedKubernetes{})); err == nil {
+		t.Error("template should be required")
+	}
+	if _, err := New(context.Background(), WithClient(mockedKubernetes{}), WithTemplate("job.yaml"), WithIdempotencyKey("key", "unknown")); err == nil {
+		t.Error("invalid on-duplicate should be error")
+	}
+}
+
+func TestNewWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	j, err := New(
+		context.Background(),
+		WithClient(mockedKubernetes{}),
+		WithTemplate("../../example/job.yaml"),
+		WithArgs("echo", "hoge"),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Logger != logger {
+		t.Error("logger should be set")
+	}
+	if !strings.Contains(buf.String(), "Received args") {
+		t.Errorf("args should be logged: %s", buf.String())
+	}
+
+	j.Logger = nil
+	if j.logger() != discardLogger {
+		t.Error("discard logger should be used by default")
+	}
+}
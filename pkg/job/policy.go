@@ -0,0 +1,130 @@
+package job
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Policy has rules which the job must satisfy before it is created.
+// The rules are checked on the job after all overrides are applied. Empty rules are not checked.
+type Policy struct {
+	// Registries which the images are pulled from, like gcr.io or registry.example.com/team.
+	// Images on Docker Hub belong to docker.io.
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+	// Resources which all containers must have limits of, like cpu and memory.
+	RequiredLimits []string `json:"requiredLimits,omitempty"`
+	// Forbid privileged containers.
+	ForbidPrivileged bool `json:"forbidPrivileged,omitempty"`
+	// Forbid hostPath volumes.
+	ForbidHostPath bool `json:"forbidHostPath,omitempty"`
+	// Forbid the host network.
+	ForbidHostNetwork bool `json:"forbidHostNetwork,omitempty"`
+	// Namespaces which the job can be run in.
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+	// Maximum activeDeadlineSeconds of the job. The job must have activeDeadlineSeconds if it is set.
+	MaxActiveDeadlineSeconds *int64 `json:"maxActiveDeadlineSeconds,omitempty"`
+}
+
+// LoadPolicy reads a policy file. Unknown fields are error, so a typo does not disable a rule.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("Invalid policy file %s: %w", file, err)
+	}
+	return &policy, nil
+}
+
+// Check returns all violations of the policy in the job.
+func (p *Policy) Check(job *v1.Job) []string {
+	violations := []string{}
+	if len(p.AllowedNamespaces) > 0 && !slices.Contains(p.AllowedNamespaces, job.Namespace) {
+		violations = append(violations, fmt.Sprintf("namespace %s is not allowed", job.Namespace))
+	}
+	if p.MaxActiveDeadlineSeconds != nil {
+		deadline := job.Spec.ActiveDeadlineSeconds
+		if deadline == nil {
+			violations = append(violations, fmt.Sprintf("activeDeadlineSeconds is required, up to %d", *p.MaxActiveDeadlineSeconds))
+		} else if *deadline > *p.MaxActiveDeadlineSeconds {
+			violations = append(violations, fmt.Sprintf("activeDeadlineSeconds %d exceeds %d", *deadline, *p.MaxActiveDeadlineSeconds))
+		}
+	}
+	podSpec := job.Spec.Template.Spec
+	if p.ForbidHostNetwork && podSpec.HostNetwork {
+		violations = append(violations, "hostNetwork is forbidden")
+	}
+	if p.ForbidHostPath {
+		for _, volume := range podSpec.Volumes {
+			if volume.HostPath != nil {
+				violations = append(violations, fmt.Sprintf("hostPath volume %s is forbidden", volume.Name))
+			}
+		}
+	}
+	containers := append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+	for _, container := range containers {
+		if len(p.AllowedRegistries) > 0 && !imageIsAllowed(container.Image, p.AllowedRegistries) {
+			violations = append(violations, fmt.Sprintf("image %s of container %s is not from allowed registries", container.Image, container.Name))
+		}
+		for _, name := range p.RequiredLimits {
+			if _, ok := container.Resources.Limits[corev1.ResourceName(name)]; !ok {
+				violations = append(violations, fmt.Sprintf("container %s does not have %s limit", container.Name, name))
+			}
+		}
+		if p.ForbidPrivileged && container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+			violations = append(violations, fmt.Sprintf("privileged container %s is forbidden", container.Name))
+		}
+	}
+	return violations
+}
+
+// imageIsAllowed checks whether the image is in one of the registries.
+func imageIsAllowed(image string, registries []string) bool {
+	name := normalizeImage(image)
+	for _, registry := range registries {
+		if strings.HasPrefix(name, strings.TrimSuffix(registry, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeImage adds the registry to the image, like docker.io/library/alpine for alpine.
+func normalizeImage(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return image
+	}
+	if !found {
+		return "docker.io/library/" + image
+	}
+	return "docker.io/" + image
+}
+
+// checkPolicy checks the job with the policy. When OverridePolicy is set, the violations are recorded
+// in the annotation instead of returning error.
+func (j *Job) checkPolicy(job *v1.Job) error {
+	if j.Policy == nil {
+		return nil
+	}
+	violations := j.Policy.Check(job)
+	if len(violations) == 0 {
+		return nil
+	}
+	if !j.OverridePolicy {
+		return fmt.Errorf("Job violates the policy: %s", strings.Join(violations, "; "))
+	}
+	j.logger().Warn("Job violates the policy, but it is overridden", "job", job.Name, "violations", violations)
+	job.Annotations = mergeMap(job.Annotations, map[string]string{
+		PolicyOverrideAnnotation: strings.Join(violations, "; "),
+	})
+	return nil
+}
@@ -0,0 +1,109 @@
+package job
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1core "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	policy, err := LoadPolicy("../../example/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.AllowedRegistries) != 2 || !policy.ForbidPrivileged || policy.MaxActiveDeadlineSeconds == nil || *policy.MaxActiveDeadlineSeconds != 3600 {
+		t.Errorf("policy does not match: %v", policy)
+	}
+
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(file, []byte("forbidPriviledged: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPolicy(file); err == nil || !strings.Contains(err.Error(), "forbidPriviledged") {
+		t.Errorf("unknown field should be error: %v", err)
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadPolicy("../../example/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := currentJob.DeepCopy()
+	job.Spec.ActiveDeadlineSeconds = ptr.To(int64(600))
+	container := &job.Spec.Template.Spec.Containers[0]
+	container.Image = "alpine:latest"
+	container.Resources.Limits = v1core.ResourceList{"cpu": {}, "memory": {}}
+	if violations := policy.Check(job); len(violations) > 0 {
+		t.Errorf("job should satisfy the policy: %v", violations)
+	}
+
+	job.Namespace = "kube-system"
+	job.Spec.ActiveDeadlineSeconds = nil
+	job.Spec.Template.Spec.HostNetwork = true
+	job.Spec.Template.Spec.Volumes = []v1core.Volume{
+		{Name: "host", VolumeSource: v1core.VolumeSource{HostPath: &v1core.HostPathVolumeSource{Path: "/"}}},
+	}
+	container.Image = "quay.io/example/alpine"
+	container.Resources.Limits = v1core.ResourceList{"cpu": {}}
+	container.SecurityContext = &v1core.SecurityContext{Privileged: ptr.To(true)}
+	violations := policy.Check(job)
+	if len(violations) != 7 {
+		t.Errorf("all violations should be reported: %v", violations)
+	}
+}
+
+func TestImageIsAllowed(t *testing.T) {
+	registries := []string{"docker.io/library", "gcr.io/my-project", "localhost:5000"}
+	allowed := []string{"alpine", "alpine:3", "docker.io/library/alpine", "gcr.io/my-project/app:v1", "localhost:5000/app"}
+	for _, image := range allowed {
+		if !imageIsAllowed(image, registries) {
+			t.Errorf("%s should be allowed", image)
+		}
+	}
+	denied := []string{"h3poteto/app", "gcr.io/other/app", "gcr.io/my-project-2/app", "quay.io/library/alpine"}
+	for _, image := range denied {
+		if imageIsAllowed(image, registries) {
+			t.Errorf("%s should not be allowed", image)
+		}
+	}
+}
+
+func TestRunJobWithPolicy(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{
+		CurrentJob: currentJob,
+		Container:  "alpine",
+		Policy:     &Policy{ForbidHostNetwork: true, AllowedNamespaces: []string{"batch"}},
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: mockedJob{},
+			},
+		},
+	}
+	if _, err := job.RunJob(context.Background()); err == nil || !strings.Contains(err.Error(), "namespace default is not allowed") {
+		t.Errorf("job should violate the policy: %v", err)
+	}
+
+	job.OverridePolicy = true
+	j, err := job.RunJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Annotations[PolicyOverrideAnnotation] != "namespace default is not allowed" {
+		t.Errorf("violations are not recorded: %v", j.Annotations)
+	}
+}
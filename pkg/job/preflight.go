@@ -0,0 +1,114 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// permission is a verb on a resource which kube-job needs.
+type permission struct {
+	Group       string
+	Resource    string
+	Subresource string
+	Verb        string
+}
+
+func (p permission) String() string {
+	resource := p.Resource
+	if len(p.Subresource) > 0 {
+		resource += "/" + p.Subresource
+	}
+	if len(p.Group) > 0 {
+		resource += "." + p.Group
+	}
+	return p.Verb + " " + resource
+}
+
+// requiredPermissions returns permissions which are used to run and wait the job.
+// When cleanup is true, permissions to remove the job and the pods are also required.
+func (j *Job) requiredPermissions(cleanup bool) []permission {
+	permissions := []permission{
+		{Group: "batch", Resource: "jobs", Verb: "create"},
+		{Group: "batch", Resource: "jobs", Verb: "get"},
+		{Resource: "pods", Verb: "list"},
+		{Resource: "pods", Verb: "get"},
+		{Resource: "pods", Subresource: "log", Verb: "get"},
+		{Resource: "events", Verb: "watch"},
+	}
+	if cleanup {
+		permissions = append(permissions,
+			permission{Group: "batch", Resource: "jobs", Verb: "delete"},
+			permission{Resource: "pods", Verb: "deletecollection"},
+		)
+	}
+	if len(j.IdempotencyKey) > 0 && j.OnDuplicate != DuplicateRun {
+		permissions = append(permissions, permission{Group: "batch", Resource: "jobs", Verb: "list"})
+	}
+	if len(j.Lock) > 0 {
+		for _, verb := range []string{"get", "create", "update"} {
+			permissions = append(permissions, permission{Group: "coordination.k8s.io", Resource: "leases", Verb: verb})
+		}
+	}
+	configMaps, secrets := j.objects(j.CurrentJob)
+	resources := []string{}
+	if len(configMaps) > 0 {
+		resources = append(resources, "configmaps")
+	}
+	if len(secrets) > 0 {
+		resources = append(resources, "secrets")
+	}
+	for _, resource := range resources {
+		for _, verb := range []string{"create", "get", "update", "delete"} {
+			permissions = append(permissions, permission{Resource: resource, Verb: verb})
+		}
+	}
+	if len(j.Artifacts) > 0 || len(j.SidecarShutdowns) > 0 {
+		permissions = append(permissions, permission{Resource: "pods", Subresource: "exec", Verb: "create"})
+	}
+	return permissions
+}
+
+// Preflight checks that the user has permissions to run the job in the namespace with SelfSubjectAccessReview,
+// and returns all missing permissions together. Set cleanup when the job is removed after it is finished.
+// It does nothing when SkipPreflight is set.
+func (j *Job) Preflight(ctx context.Context, cleanup bool) error {
+	if j.SkipPreflight {
+		return nil
+	}
+	namespace := j.CurrentJob.Namespace
+	missing := []string{}
+	checked := map[permission]bool{}
+	for _, p := range j.requiredPermissions(cleanup) {
+		if checked[p] {
+			continue
+		}
+		checked[p] = true
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   namespace,
+					Verb:        p.Verb,
+					Group:       p.Group,
+					Resource:    p.Resource,
+					Subresource: p.Subresource,
+				},
+			},
+		}
+		result, err := j.client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "Failed to check permission to %s", p)
+		}
+		if !result.Status.Allowed {
+			missing = append(missing, p.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Missing permissions in namespace %s: %s", namespace, strings.Join(missing, ", "))
+	}
+	return nil
+}
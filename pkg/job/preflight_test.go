@@ -0,0 +1,115 @@
+package job
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1authorization "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+type mockedAuthorizationV1 struct {
+	authorizationv1.AuthorizationV1Interface
+	mockedReview *mockedSelfSubjectAccessReview
+}
+
+type mockedSelfSubjectAccessReview struct {
+	authorizationv1.SelfSubjectAccessReviewInterface
+	// Permissions which are denied, like "get pods/log".
+	denied   map[string]bool
+	reviewed []string
+}
+
+func (m mockedAuthorizationV1) SelfSubjectAccessReviews() authorizationv1.SelfSubjectAccessReviewInterface {
+	return m.mockedReview
+}
+
+func (m *mockedSelfSubjectAccessReview) Create(ctx context.Context, review *v1authorization.SelfSubjectAccessReview, options metav1.CreateOptions) (*v1authorization.SelfSubjectAccessReview, error) {
+	attributes := review.Spec.ResourceAttributes
+	p := permission{Group: attributes.Group, Resource: attributes.Resource, Subresource: attributes.Subresource, Verb: attributes.Verb}
+	m.reviewed = append(m.reviewed, attributes.Namespace+":"+p.String())
+	result := review.DeepCopy()
+	result.Status.Allowed = !m.denied[p.String()]
+	return result, nil
+}
+
+func TestPreflight(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := &mockedSelfSubjectAccessReview{}
+	job := &Job{
+		CurrentJob: currentJob,
+		Container:  "alpine",
+		client: mockedKubernetes{
+			mockedAuthorization: mockedAuthorizationV1{mockedReview: review},
+		},
+	}
+	if err := job.Preflight(context.Background(), false); err != nil {
+		t.Error(err)
+	}
+	if len(review.reviewed) != 6 || review.reviewed[0] != "default:create jobs.batch" || review.reviewed[5] != "default:watch events" {
+		t.Errorf("unexpected reviews: %v", review.reviewed)
+	}
+	review.reviewed = nil
+	if err := job.Preflight(context.Background(), true); err != nil {
+		t.Error(err)
+	}
+	if len(review.reviewed) != 8 {
+		t.Errorf("permissions to clean up should be reviewed: %v", review.reviewed)
+	}
+	review.reviewed = nil
+	if err := job.Validate(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if len(review.reviewed) != 8 {
+		t.Errorf("permissions should be reviewed in Validate: %v", review.reviewed)
+	}
+
+	review.denied = map[string]bool{"get pods/log": true, "delete jobs.batch": true}
+	err = job.Preflight(context.Background(), true)
+	if err == nil {
+		t.Fatal("missing permissions should be error")
+	}
+	if !strings.Contains(err.Error(), "get pods/log, delete jobs.batch") {
+		t.Errorf("error does not have missing permissions: %v", err)
+	}
+
+	if child := job.withParameters(Parameters{"tenant": "a"}, nil); !child.SkipPreflight {
+		t.Error("jobs in the matrix should not check the permissions again")
+	}
+
+	job.SkipPreflight = true
+	if err := job.Preflight(context.Background(), true); err != nil {
+		t.Errorf("preflight should be skipped: %v", err)
+	}
+	if err := job.Validate(context.Background()); err != nil {
+		t.Errorf("preflight should be skipped in Validate: %v", err)
+	}
+}
+
+func TestRequiredPermissions(t *testing.T) {
+	job := &Job{
+		IdempotencyKey:   "key",
+		Lock:             "migration",
+		SidecarShutdowns: []SidecarShutdown{{Container: "proxy", Port: 15000, Path: "/quitquitquit"}, {Container: "agent", Command: []string{"kill", "1"}}},
+	}
+	permissions := []string{}
+	for _, p := range job.requiredPermissions(true) {
+		permissions = append(permissions, p.String())
+	}
+	for _, expected := range []string{"list jobs.batch", "update leases.coordination.k8s.io", "create pods/exec"} {
+		found := false
+		for _, p := range permissions {
+			if p == expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s is not required: %v", expected, permissions)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// RetryOnEvicted retries the job when the pod is evicted.
+	RetryOnEvicted = "evicted"
+	// RetryOnOOMKilled retries the job when the target container is killed by OOM killer.
+	RetryOnOOMKilled = "oomkilled"
+	// RetryOnPreempted retries the job when the pod is preempted, or the node is shut down.
+	RetryOnPreempted = "preempted"
+	// RetryOnDeadlineExceeded retries the job when the job exceeds activeDeadlineSeconds.
+	RetryOnDeadlineExceeded = "deadline-exceeded"
+
+	retryOnExitCodePrefix = "exit-code="
+
+	maxRetryBackoff = 5 * time.Minute
+)
+
+// RetryPolicy describes when a failed job is run again with a new name.
+type RetryPolicy struct {
+	// Maximum number of retries. If you set 0, the job is not retried.
+	Retries int
+	// Failure reasons to retry, like evicted, oomkilled, preempted, deadline-exceeded and exit-code=75.
+	On []string
+	// Wait time before the first retry. It is doubled for each retry, up to 5 minutes.
+	Backoff time.Duration
+}
+
+// ParseRetryOn parses comma separated failure reasons, and validates them.
+func ParseRetryOn(value string) ([]string, error) {
+	reasons := []string{}
+	for _, reason := range strings.Split(value, ",") {
+		reason = strings.TrimSpace(reason)
+		switch {
+		case len(reason) == 0:
+			continue
+		case reason == RetryOnEvicted, reason == RetryOnOOMKilled, reason == RetryOnPreempted, reason == RetryOnDeadlineExceeded:
+		case strings.HasPrefix(reason, retryOnExitCodePrefix):
+			if _, err := strconv.Atoi(strings.TrimPrefix(reason, retryOnExitCodePrefix)); err != nil {
+				return nil, fmt.Errorf("Invalid exit code in %q", reason)
+			}
+		default:
+			return nil, fmt.Errorf("Unknown retry reason %q, please set 'evicted', 'oomkilled', 'preempted', 'deadline-exceeded' or 'exit-code=N'", reason)
+		}
+		reasons = append(reasons, reason)
+	}
+	return reasons, nil
+}
+
+// backoff returns wait time before the retry. attempt starts from 1.
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	wait := r.Backoff
+	for i := 1; i < attempt && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return wait
+}
+
+// retryReason returns a failure reason of the job which matches the policy.
+// If the failure should not be retried, it returns an empty string.
+func (r RetryPolicy) retryReason(reasons []string) string {
+	for _, reason := range reasons {
+		for _, on := range r.On {
+			if reason == on {
+				return reason
+			}
+		}
+	}
+	return ""
+}
+
+// classifyFailure inspects the job and the pods, and returns failure reasons of them.
+func classifyFailure(job *v1.Job, pods []corev1.Pod, containerName string) []string {
+	reasons := []string{}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == v1.JobFailed && condition.Reason == "DeadlineExceeded" {
+			reasons = append(reasons, RetryOnDeadlineExceeded)
+		}
+	}
+	for _, pod := range pods {
+		switch pod.Status.Reason {
+		case "Evicted":
+			reasons = append(reasons, RetryOnEvicted)
+		case "Preempting", "Shutdown", "NodeShutdown", "Terminated":
+			reasons = append(reasons, RetryOnPreempted)
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type != corev1.DisruptionTarget || condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Reason {
+			case "PreemptionByScheduler", "TerminationByKubelet", "DeletionByTaintManager":
+				reasons = append(reasons, RetryOnPreempted)
+			case "EvictionByEvictionAPI":
+				reasons = append(reasons, RetryOnEvicted)
+			}
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != containerName || status.State.Terminated == nil {
+				continue
+			}
+			if status.State.Terminated.Reason == "OOMKilled" {
+				reasons = append(reasons, RetryOnOOMKilled)
+			}
+			reasons = append(reasons, retryOnExitCodePrefix+strconv.Itoa(int(status.State.Terminated.ExitCode)))
+		}
+	}
+	return reasons
+}
+
+// failureReasons gets the current job and the pods, and classifies the failure.
+func (j *Job) failureReasons(ctx context.Context) ([]string, error) {
+	job, err := j.client.BatchV1().Jobs(j.CurrentJob.Namespace).Get(ctx, j.CurrentJob.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := j.FindPods(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	containerName, err := j.targetContainerName(job)
+	if err != nil {
+		return nil, err
+	}
+	return classifyFailure(job, pods, containerName), nil
+}
@@ -0,0 +1,114 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/batch/v1"
+	v1core "k8s.io/api/core/v1"
+)
+
+func TestParseRetryOn(t *testing.T) {
+	reasons, err := ParseRetryOn("evicted, oomkilled,preempted,exit-code=75")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(reasons) != 4 || reasons[1] != "oomkilled" || reasons[3] != "exit-code=75" {
+		t.Errorf("reasons do not match: %v", reasons)
+	}
+
+	if _, err := ParseRetryOn("evicted,unknown"); err == nil {
+		t.Error("unknown reason should be invalid")
+	}
+	if _, err := ParseRetryOn("exit-code=abc"); err == nil {
+		t.Error("invalid exit code should be invalid")
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		Backoff: 10 * time.Second,
+	}
+	if policy.backoff(1) != 10*time.Second {
+		t.Errorf("first backoff does not match: %s", policy.backoff(1))
+	}
+	if policy.backoff(3) != 40*time.Second {
+		t.Errorf("third backoff does not match: %s", policy.backoff(3))
+	}
+	if policy.backoff(10) != maxRetryBackoff {
+		t.Errorf("backoff should be capped: %s", policy.backoff(10))
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	job := &v1.Job{
+		Status: v1.JobStatus{
+			Conditions: []v1.JobCondition{
+				{
+					Type:   v1.JobFailed,
+					Reason: "DeadlineExceeded",
+				},
+			},
+		},
+	}
+	evictedPod := v1core.Pod{
+		Status: v1core.PodStatus{
+			Phase:  v1core.PodFailed,
+			Reason: "Evicted",
+		},
+	}
+	preemptedPod := v1core.Pod{
+		Status: v1core.PodStatus{
+			Phase: v1core.PodFailed,
+			Conditions: []v1core.PodCondition{
+				{
+					Type:   v1core.DisruptionTarget,
+					Status: v1core.ConditionTrue,
+					Reason: "PreemptionByScheduler",
+				},
+			},
+		},
+	}
+	oomKilledPod := v1core.Pod{
+		Status: v1core.PodStatus{
+			Phase: v1core.PodFailed,
+			ContainerStatuses: []v1core.ContainerStatus{
+				{
+					Name: "alpine",
+					State: v1core.ContainerState{
+						Terminated: &v1core.ContainerStateTerminated{
+							ExitCode: 137,
+							Reason:   "OOMKilled",
+						},
+					},
+				},
+			},
+		},
+	}
+	reasons := classifyFailure(job, []v1core.Pod{evictedPod, preemptedPod, oomKilledPod}, "alpine")
+	expected := []string{"deadline-exceeded", "evicted", "preempted", "oomkilled", "exit-code=137"}
+	if len(reasons) != len(expected) {
+		t.Fatalf("reasons do not match: %v", reasons)
+	}
+	for i := range expected {
+		if reasons[i] != expected[i] {
+			t.Errorf("reasons do not match: %v", reasons)
+		}
+	}
+}
+
+func TestRetryReason(t *testing.T) {
+	policy := RetryPolicy{
+		Retries: 2,
+		On:      []string{"evicted", "exit-code=75"},
+	}
+	if policy.retryReason([]string{"exit-code=1"}) != "" {
+		t.Error("exit-code=1 should not be retried")
+	}
+	if policy.retryReason([]string{"exit-code=75"}) != "exit-code=75" {
+		t.Error("exit-code=75 should be retried")
+	}
+	if policy.retryReason([]string{"oomkilled", "evicted"}) != "evicted" {
+		t.Error("evicted should be retried")
+	}
+}
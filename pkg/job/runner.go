@@ -13,21 +13,67 @@ At first, you have to prepare yaml for job, and provide a command to override th
 
 For example:
 
-	j, err := job.NewJob("$HOME/.kube/config", "job-template.yaml", "echo hoge", "target-container-name", 0 * time.Second)
+	j, err := job.New(
+	    ctx,
+	    job.WithKubeconfig("$HOME/.kube/config"),
+	    job.WithTemplate("job-template.yaml"),
+	    job.WithArgs("echo", "hoge"),
+	    job.WithContainer("target-container-name"),
+	)
 	if err != nil {
 	    return err
 	}
 
 	// Run the job
-	running, err := j.RunJob()
+	running, err := j.RunJob(ctx)
 	if err != nil {
 	    return err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	err = j.WaitJob(ctx, running, false)
+
+If you already have a kubernetes client, you can inject it instead of the kubeconfig.
+
+	j, err := job.New(
+	    ctx,
+	    job.WithClient(client),
+	    job.WithTemplateJob(template),
+	    job.WithArgs("echo", "hoge"),
+	)
+
+The package does not write logs by default. Set a *slog.Logger with WithLogger to see diagnostic messages.
+
+NewJob is also available, which receives the overrides as arguments.
+
+Validate checks the overrides and the permissions in the namespace with SelfSubjectAccessReview before running the job.
+Preflight checks only the permissions. Set cleanup to false if you do not remove the job.
+
+	if err := j.Validate(ctx); err != nil {
+	    return err
+	}
+
+# Run a job and clean up
+
+RunAndCleanup validates and runs the job, waits the completion of the job with streaming the logs, and removes the job and the pods.
+
+	err = j.RunAndCleanup(ctx, job.All.String(), false, true)
+
+All methods receive a context. When the context is canceled, waiting the job is stopped,
+but the job and the pods are still removed by RunAndCleanup with its own timeout.
+
+# Lifecycle events
 
-	err = j.WaitJob(ctx, running)
+If you want to react to the lifecycle of the job, set an EventHandler.
+
+	j, err := job.New(
+	    ctx,
+	    job.WithTemplate("job-template.yaml"),
+	    job.WithEventHandler(job.EventHandlerFunc(func(e job.Event) {
+	        if e.Type == job.EventContainerTerminated {
+	            fmt.Printf("%s exited with %d\n", e.Container, e.ExitCode)
+	        }
+	    })),
+	)
 
 # Polling the logs
 
@@ -35,11 +81,11 @@ You can polling the logs with stream.
 
 For example:
 
-	// j is a Job struct
-	watcher := NewWatcher(j.client, j.Container)
+	// client is a kubernetes client
+	watcher := job.NewWatcher(client, "target-container-name")
 
 	// running is a batchv1.Job struct
-	err := watcher.Watch(running, ctx)
+	err := watcher.Watch(ctx, running)
 	if err != nil {
 	    return err
 	}
@@ -50,7 +96,7 @@ import (
 	"context"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/pkg/errors"
 )
 
 // CleanupType for enum.
@@ -69,60 +115,138 @@ func (c CleanupType) String() string {
 	return [...]string{"all", "succeeded", "failed"}[c]
 }
 
+// cleanupTimeout is the timeout to remove the job and the pods.
+const cleanupTimeout = 1 * time.Minute
+
 // Run a command on kubernetes cluster, and watch the log.
-func (j *Job) Run(ignoreSidecar bool, followLogs bool) error {
+// When ctx is canceled, it stops waiting the job, but the job is not removed.
+func (j *Job) Run(ctx context.Context, ignoreSidecar bool, followLogs bool) error {
 	if ignoreSidecar {
-		log.Info("Ignore sidecar containers")
+		j.logger().Info("Ignore sidecar containers")
+	}
+	if len(j.Artifacts) > 0 && !followLogs {
+		return errors.New("Artifacts can not be copied without following the job")
+	}
+	if len(j.SecretEnv) > 0 && !followLogs {
+		// The Secret is removed after the job is finished, so it would remain in the cluster.
+		return errors.New("Secret env can not be removed without following the job")
 	}
-	running, err := j.RunJob()
+	running, err := j.RunJob(ctx)
 	if err != nil {
-		log.Error(err)
+		j.logger().Error("Failed to run the job", "error", err)
 		return err
 	}
-	log.Infof("Starting job: %s", running.Name)
-	ctx, cancel := context.WithCancel(context.Background())
+	waitCtx, cancel := context.WithCancel(ctx)
 	if j.Timeout != 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), j.Timeout)
+		waitCtx, cancel = context.WithTimeout(ctx, j.Timeout)
 	}
 	defer cancel()
 
 	err = nil
 	if followLogs {
-		watcher := NewWatcher(j.client, j.Container)
+		containerName, err := j.targetContainerName(running)
+		if err != nil {
+			return err
+		}
+		watcher := NewWatcher(j.client, containerName)
+		watcher.Output = j.Output
+		watcher.Logger = j.Logger
 		go func() {
-			err := watcher.Watch(running, ctx)
+			err := watcher.Watch(waitCtx, running)
 			if err != nil {
-				log.Error(err)
+				j.logger().Error("Failed to watch the job", "job", running.Name, "error", err)
 			}
 		}()
 
-		err = j.WaitJob(ctx, running, ignoreSidecar)
-		time.Sleep(10 * time.Second)
+		err = j.WaitJob(waitCtx, running, ignoreSidecar)
+		// Wait a little to receive the rest of the logs.
+		select {
+		case <-ctx.Done():
+		case <-time.After(10 * time.Second):
+		}
 	} else {
-		log.Info("Not following logs. Provide --follow.")
+		j.logger().Info("Not following logs. Provide --follow.")
 	}
 
 	return err
 }
 
 // RunAndCleanup executes a command and clean up the job and pods.
-func (j *Job) RunAndCleanup(cleanupType string, ignoreSidecar bool, followLogs bool) error {
-	if err := j.Validate(); err != nil {
-		return err
+// If the job is failed by a reason in the retry policy, a new job is run after the backoff.
+// When Lock is set, the lock is held until the job is finished, so followLogs is required.
+// The job is cleaned up even if ctx is canceled, because the cleanup uses its own timeout.
+func (j *Job) RunAndCleanup(ctx context.Context, cleanupType string, ignoreSidecar bool, followLogs bool) error {
+	if len(j.Lock) > 0 && !followLogs {
+		return errors.New("Lock can not be held without following the job")
 	}
-	err := j.Run(ignoreSidecar, followLogs)
-	if !followLogs {
-		log.Debug("Skipping cleanup. Streaming logs not enabled.")
+	if err := j.validate(); err != nil {
 		return err
 	}
-	if !shouldCleanup(cleanupType, err) {
-		log.Info("Job should no clean up")
+	// The job is removed only when the logs are followed.
+	if err := j.Preflight(ctx, followLogs); err != nil {
 		return err
 	}
-	if e := j.Cleanup(); e != nil {
-		return e
+	if len(j.Lock) > 0 {
+		release, err := j.holdLock(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
 	}
-	return err
+	j.retried = false
+	for attempt := 1; ; attempt++ {
+		err := j.Run(ctx, ignoreSidecar, followLogs)
+		if !followLogs {
+			j.logger().Debug("Skipping cleanup. Streaming logs not enabled.")
+			return err
+		}
+		reason := ""
+		if err != nil && ctx.Err() == nil && attempt <= j.Retry.Retries {
+			reasons, e := j.failureReasons(ctx)
+			if e != nil {
+				j.logger().Warn("Could not classify the failure", "job", j.CurrentJob.Name, "error", e)
+			}
+			reason = j.Retry.retryReason(reasons)
+		}
+		if !shouldCleanup(cleanupType, err) {
+			j.logger().Info("Job should no clean up", "job", j.CurrentJob.Name)
+			if len(j.SecretEnv) > 0 && j.attached == nil {
+				removeCtx, cancel := detachedContext(ctx)
+				if e := j.removeSecretEnv(removeCtx); e != nil {
+					j.logger().Warn("Failed to remove the secret env", "job", j.CurrentJob.Name, "error", e)
+				}
+				cancel()
+			}
+		} else if e := j.cleanupDetached(ctx); e != nil {
+			return e
+		}
+		if len(reason) == 0 {
+			return err
+		}
+
+		wait := j.Retry.backoff(attempt)
+		emitEvent(j.Events, j.CurrentJob, Event{Type: EventRetry, Attempt: attempt, Reason: reason, Backoff: wait, Err: err})
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		j.CurrentJob.SetName(generateRandomName(j.baseName()))
+		j.retried = true
+	}
+}
+
+// cleanupDetached removes the job on a context which is not canceled with ctx.
+func (j *Job) cleanupDetached(ctx context.Context) error {
+	cleanupCtx, cancel := detachedContext(ctx)
+	defer cancel()
+	return j.Cleanup(cleanupCtx)
+}
+
+// detachedContext returns a context which keeps the values of ctx, but is not canceled with ctx.
+// It is canceled after cleanupTimeout instead.
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
 }
 
 func shouldCleanup(cleanupType string, jobResult error) bool {
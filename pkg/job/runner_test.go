@@ -1,6 +1,7 @@
 package job
 
 import (
+	"context"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -50,3 +51,16 @@ func TestShouldCleanupFailedWithoutError(t *testing.T) {
 		t.Error("should be false when specified 'failed' without error")
 	}
 }
+
+func TestDetachedContextWithCanceledParent(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx, cancelDetached := detachedContext(parent)
+	defer cancelDetached()
+	if ctx.Err() != nil {
+		t.Errorf("should not be canceled with the parent: %v", ctx.Err())
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("should have a deadline")
+	}
+}
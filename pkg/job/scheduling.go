@@ -0,0 +1,119 @@
+package job
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// ParseNodeSelector parses KEY=VALUE definitions.
+func ParseNodeSelector(values []string) (map[string]string, error) {
+	return ParseKeyValues(values)
+}
+
+// ParseToleration parses a toleration definition.
+// The format is KEY[=VALUE][:EFFECT]. If VALUE is omitted, the operator is Exists.
+// If EFFECT is omitted, all effects are tolerated.
+func ParseToleration(value string) (corev1.Toleration, error) {
+	toleration := corev1.Toleration{}
+	keyValue, effect, _ := strings.Cut(value, ":")
+	toleration.Effect = corev1.TaintEffect(effect)
+	key, v, found := strings.Cut(keyValue, "=")
+	toleration.Key = key
+	if found {
+		toleration.Operator = corev1.TolerationOpEqual
+		toleration.Value = v
+	} else {
+		toleration.Operator = corev1.TolerationOpExists
+	}
+	if len(toleration.Key) == 0 && toleration.Operator == corev1.TolerationOpEqual {
+		return toleration, fmt.Errorf("Invalid toleration %q, key is required with value", value)
+	}
+	return toleration, nil
+}
+
+// LoadAffinity reads an affinity definition file, which is the same as spec.affinity of the pod.
+// Unknown fields are error, so a typo does not drop the constraint.
+func LoadAffinity(file string) (*corev1.Affinity, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var affinity corev1.Affinity
+	if err := yaml.UnmarshalStrict(data, &affinity); err != nil {
+		return nil, fmt.Errorf("Invalid affinity file %s: %w", file, err)
+	}
+	return &affinity, nil
+}
+
+// validateScheduling checks scheduling overrides, and returns all errors together.
+func (j *Job) validateScheduling() error {
+	errs := []string{}
+	for k, v := range j.NodeSelector {
+		for _, msg := range validation.IsQualifiedName(k) {
+			errs = append(errs, fmt.Sprintf("node selector key %q: %s", k, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(v) {
+			errs = append(errs, fmt.Sprintf("node selector value %q: %s", v, msg))
+		}
+	}
+	for _, toleration := range j.Tolerations {
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			errs = append(errs, fmt.Sprintf("toleration effect %q: must be NoSchedule, PreferNoSchedule or NoExecute", toleration.Effect))
+		}
+		if len(toleration.Key) > 0 {
+			for _, msg := range validation.IsQualifiedName(toleration.Key) {
+				errs = append(errs, fmt.Sprintf("toleration key %q: %s", toleration.Key, msg))
+			}
+		}
+	}
+	if len(j.PriorityClassName) > 0 {
+		for _, msg := range validation.IsDNS1123Subdomain(j.PriorityClassName) {
+			errs = append(errs, fmt.Sprintf("priority class %q: %s", j.PriorityClassName, msg))
+		}
+	}
+	if len(j.ServiceAccountName) > 0 {
+		for _, msg := range validation.IsDNS1123Subdomain(j.ServiceAccountName) {
+			errs = append(errs, fmt.Sprintf("service account %q: %s", j.ServiceAccountName, msg))
+		}
+	}
+	if j.ActiveDeadlineSeconds != nil && *j.ActiveDeadlineSeconds <= 0 {
+		errs = append(errs, fmt.Sprintf("active deadline %d: must be greater than 0", *j.ActiveDeadlineSeconds))
+	}
+	if j.BackoffLimit != nil && *j.BackoffLimit < 0 {
+		errs = append(errs, fmt.Sprintf("backoff limit %d: must be greater than or equal to 0", *j.BackoffLimit))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Invalid scheduling overrides: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// applyScheduling overrides the pod template and the job spec with the scheduling overrides.
+func (j *Job) applyScheduling(job *v1.Job) {
+	podSpec := &job.Spec.Template.Spec
+	podSpec.NodeSelector = mergeMap(podSpec.NodeSelector, j.NodeSelector)
+	podSpec.Tolerations = append(podSpec.Tolerations, j.Tolerations...)
+	if j.Affinity != nil {
+		podSpec.Affinity = j.Affinity.DeepCopy()
+	}
+	if len(j.PriorityClassName) > 0 {
+		podSpec.PriorityClassName = j.PriorityClassName
+	}
+	if len(j.ServiceAccountName) > 0 {
+		podSpec.ServiceAccountName = j.ServiceAccountName
+	}
+	if j.ActiveDeadlineSeconds != nil {
+		job.Spec.ActiveDeadlineSeconds = j.ActiveDeadlineSeconds
+	}
+	if j.BackoffLimit != nil {
+		job.Spec.BackoffLimit = j.BackoffLimit
+	}
+}
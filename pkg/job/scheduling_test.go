@@ -0,0 +1,136 @@
+package job
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1core "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestParseNodeSelector(t *testing.T) {
+	selector, err := ParseNodeSelector([]string{"pool=batch", "kubernetes.io/arch=amd64"})
+	if err != nil {
+		t.Error(err)
+	}
+	if selector["pool"] != "batch" || selector["kubernetes.io/arch"] != "amd64" {
+		t.Errorf("node selector does not match: %v", selector)
+	}
+	if _, err := ParseNodeSelector([]string{"pool"}); err == nil {
+		t.Error("node selector without value should be invalid")
+	}
+}
+
+func TestParseToleration(t *testing.T) {
+	toleration, err := ParseToleration("dedicated=batch:NoSchedule")
+	if err != nil {
+		t.Error(err)
+	}
+	if toleration.Key != "dedicated" || toleration.Operator != v1core.TolerationOpEqual || toleration.Value != "batch" || toleration.Effect != v1core.TaintEffectNoSchedule {
+		t.Errorf("toleration does not match: %v", toleration)
+	}
+
+	toleration, err = ParseToleration("spot")
+	if err != nil {
+		t.Error(err)
+	}
+	if toleration.Key != "spot" || toleration.Operator != v1core.TolerationOpExists || toleration.Effect != "" {
+		t.Errorf("toleration does not match: %v", toleration)
+	}
+
+	if _, err := ParseToleration("=batch:NoSchedule"); err == nil {
+		t.Error("toleration without key should be invalid")
+	}
+}
+
+func TestLoadAffinity(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "affinity.yaml")
+	content := `nodeAffinity:
+  requiredDuringSchedulingIgnoredDuringExecution:
+    nodeSelectorTerms:
+      - matchExpressions:
+          - key: pool
+            operator: In
+            values: [batch]
+`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	affinity, err := LoadAffinity(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || terms[0].MatchExpressions[0].Key != "pool" {
+		t.Errorf("affinity does not match: %v", affinity)
+	}
+
+	if err := os.WriteFile(file, []byte(strings.Replace(content, "nodeAffinity", "nodeAfinity", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAffinity(file); err == nil || !strings.Contains(err.Error(), "nodeAfinity") {
+		t.Errorf("unknown field should be error: %v", err)
+	}
+}
+
+func TestValidateScheduling(t *testing.T) {
+	job := &Job{
+		NodeSelector:          map[string]string{"pool": "batch"},
+		Tolerations:           []v1core.Toleration{{Key: "dedicated", Operator: v1core.TolerationOpExists, Effect: v1core.TaintEffectNoSchedule}},
+		PriorityClassName:     "high-priority",
+		ServiceAccountName:    "migrator",
+		ActiveDeadlineSeconds: ptr.To(int64(600)),
+		BackoffLimit:          ptr.To(int32(0)),
+	}
+	if err := job.validateScheduling(); err != nil {
+		t.Error(err)
+	}
+
+	job = &Job{
+		NodeSelector:          map[string]string{"pool": "batch pool"},
+		Tolerations:           []v1core.Toleration{{Key: "dedicated", Effect: "NoRun"}},
+		ServiceAccountName:    "Migrator",
+		ActiveDeadlineSeconds: ptr.To(int64(0)),
+		BackoffLimit:          ptr.To(int32(-1)),
+	}
+	if err := job.validateScheduling(); err == nil {
+		t.Error("invalid scheduling overrides should be error")
+	}
+}
+
+func TestRunJobWithScheduling(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	job := &Job{
+		CurrentJob:            currentJob,
+		Container:             "alpine",
+		NodeSelector:          map[string]string{"pool": "batch"},
+		Tolerations:           []v1core.Toleration{{Key: "dedicated", Operator: v1core.TolerationOpExists}},
+		PriorityClassName:     "high-priority",
+		ServiceAccountName:    "migrator",
+		ActiveDeadlineSeconds: ptr.To(int64(600)),
+		BackoffLimit:          ptr.To(int32(3)),
+		client: mockedKubernetes{
+			mockedBatch: mockedBatchV1{
+				mockedJob: mockedJob{},
+			},
+		},
+	}
+
+	j, err := job.RunJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := j.Spec.Template.Spec
+	if spec.NodeSelector["pool"] != "batch" || len(spec.Tolerations) != 1 || spec.PriorityClassName != "high-priority" || spec.ServiceAccountName != "migrator" {
+		t.Errorf("pod spec is not overridden: %v", spec)
+	}
+	if *j.Spec.ActiveDeadlineSeconds != 600 || *j.Spec.BackoffLimit != 3 {
+		t.Errorf("job spec is not overridden: %v", j.Spec)
+	}
+}
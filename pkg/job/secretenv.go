@@ -0,0 +1,115 @@
+package job
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// secretKey is the pattern of keys in a Secret.
+var secretKey = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// ParseEnvFile reads KEY=VALUE lines in the env file, like .env.
+// Empty lines and lines starting with # are ignored, and export and quotes around the value are removed.
+func ParseEnvFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		k, v, found := strings.Cut(text, "=")
+		k = strings.TrimSpace(k)
+		if !found || len(k) == 0 {
+			return nil, fmt.Errorf("Invalid line %d in %s, it must be KEY=VALUE", line, file)
+		}
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		env[k] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func validateSecretEnv(env map[string]string) error {
+	size := 0
+	for k, v := range env {
+		if !secretKey.MatchString(k) {
+			return fmt.Errorf("Invalid secret env name %q, it must consist of alphanumeric characters, '-', '_' or '.'", k)
+		}
+		size += len(k) + len(v)
+	}
+	if size > maxObjectSize {
+		return fmt.Errorf("Secret env is %d bytes, which exceeds the limit of a Secret", size)
+	}
+	return nil
+}
+
+// secretEnvName returns the name of the Secret which has the secret env of the job.
+func secretEnvName(jobName string) string {
+	return jobName + "-secret-env"
+}
+
+// applySecretEnv sets the environment variables of the container to refer to the Secret.
+// The values are only in the Secret, and not in the job.
+func (j *Job) applySecretEnv(job *v1.Job, index int) {
+	keys := make([]string, 0, len(j.SecretEnv))
+	for k := range j.SecretEnv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		setEnv(&job.Spec.Template.Spec.Containers[index], corev1.EnvVar{
+			Name: k,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretEnvName(job.Name)},
+					Key:                  k,
+				},
+			},
+		})
+	}
+}
+
+// secretEnvSecret returns the Secret which has the secret env of the job. If there is no secret env, it returns nil.
+func (j *Job) secretEnvSecret(job *v1.Job) *corev1.Secret {
+	if len(j.SecretEnv) == 0 {
+		return nil
+	}
+	secret := &corev1.Secret{ObjectMeta: j.objectMeta(job, secretEnvName(job.Name)), Data: map[string][]byte{}}
+	for k, v := range j.SecretEnv {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+// removeSecretEnv removes the Secret of the secret env. It is removed even if the job is not cleaned up,
+// so the values do not remain in the cluster.
+func (j *Job) removeSecretEnv(ctx context.Context) error {
+	err := j.client.CoreV1().Secrets(j.CurrentJob.Namespace).Delete(ctx, secretEnvName(j.CurrentJob.Name), metav1.DeleteOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
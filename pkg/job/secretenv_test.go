@@ -0,0 +1,143 @@
+package job
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".env")
+	content := `# database
+DATABASE_URL=postgres://user:pass@db/app?sslmode=disable
+export API_KEY="quoted value"
+TOKEN='single'
+
+EMPTY=
+`
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	env, err := ParseEnvFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"DATABASE_URL": "postgres://user:pass@db/app?sslmode=disable",
+		"API_KEY":      "quoted value",
+		"TOKEN":        "single",
+		"EMPTY":        "",
+	}
+	if len(env) != len(expected) {
+		t.Errorf("env is not parsed: %v", env)
+	}
+	for k, v := range expected {
+		if env[k] != v {
+			t.Errorf("%s is %q, expected %q", k, env[k], v)
+		}
+	}
+
+	if err := os.WriteFile(file, []byte("INVALID\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseEnvFile(file); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("invalid line should be error: %v", err)
+	}
+}
+
+func TestValidateSecretEnv(t *testing.T) {
+	if err := validateSecretEnv(map[string]string{"API_KEY": "secret"}); err != nil {
+		t.Error(err)
+	}
+	if err := validateSecretEnv(map[string]string{"API KEY": "secret"}); err == nil {
+		t.Error("invalid name should be error")
+	}
+}
+
+func TestRunJobWithSecretEnv(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewClientset()
+	job := &Job{
+		CurrentJob: currentJob,
+		Container:  "alpine",
+		SecretEnv:  map[string]string{"HOGE": "secret-value", "API_KEY": "api-key-value"},
+		client:     client,
+	}
+	ctx := context.Background()
+
+	j, err := job.RunJob(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := j.Spec.Template.Spec.Containers[0].Env
+	if len(env) != 2 || env[0].Name != "HOGE" || env[1].Name != "API_KEY" {
+		t.Fatalf("env is not overridden: %v", env)
+	}
+	for _, e := range env {
+		if len(e.Value) > 0 || e.ValueFrom == nil || e.ValueFrom.SecretKeyRef.Name != j.Name+"-secret-env" || e.ValueFrom.SecretKeyRef.Key != e.Name {
+			t.Errorf("env does not refer to the secret: %v", e)
+		}
+	}
+	rendered, err := yaml.Marshal(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(rendered), "secret-value") || strings.Contains(string(rendered), "api-key-value") {
+		t.Error("values of the secret env should not be in the job")
+	}
+
+	secret, err := client.CoreV1().Secrets(j.Namespace).Get(ctx, j.Name+"-secret-env", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["HOGE"]) != "secret-value" || string(secret.Data["API_KEY"]) != "api-key-value" {
+		t.Errorf("secret does not have the values: %v", secret.Data)
+	}
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].Name != j.Name {
+		t.Errorf("secret is not owned by the job: %v", secret.OwnerReferences)
+	}
+
+	if err := job.removeSecretEnv(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Secrets(j.Namespace).Get(ctx, j.Name+"-secret-env", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("secret is not removed: %v", err)
+	}
+	if err := job.Cleanup(ctx); err != nil {
+		t.Errorf("removed secret should be ignored in the cleanup: %v", err)
+	}
+}
+
+func TestRunWithSecretEnvWithoutFollow(t *testing.T) {
+	currentJob, err := readJobFromFile("../../example/job.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewClientset()
+	job := &Job{
+		CurrentJob: currentJob,
+		Container:  "alpine",
+		SecretEnv:  map[string]string{"API_KEY": "api-key-value"},
+		client:     client,
+	}
+	if err := job.Run(context.Background(), false, false); err == nil {
+		t.Error("secret env without following the job should be error")
+	}
+	secrets, err := client.CoreV1().Secrets(currentJob.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets.Items) > 0 {
+		t.Errorf("secret should not be created: %v", secrets.Items)
+	}
+}
@@ -0,0 +1,179 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	shellwords "github.com/mattn/go-shellwords"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// SidecarShutdown describes how to stop a sidecar container after the target container is terminated.
+// Either Path or Command is set.
+type SidecarShutdown struct {
+	// Sidecar container name.
+	Container string
+	// Host of the shutdown endpoint, localhost or 127.0.0.1.
+	Host string
+	// Port of the shutdown endpoint, like 15020 for istio-proxy.
+	Port int
+	// Path of the shutdown endpoint, like /quitquitquit.
+	// The endpoint is requested with POST by curl or wget in the sidecar container,
+	// so endpoints which only accept requests from the loopback address can be used.
+	Path string
+	// Command which is executed in the sidecar container, like ["/bin/kill", "1"].
+	Command []string
+}
+
+// ParseSidecarShutdown parses a sidecar shutdown definition.
+// The format is NAME=http://localhost:PORT/PATH or NAME=exec:COMMAND.
+func ParseSidecarShutdown(value string) (SidecarShutdown, error) {
+	name, method, found := strings.Cut(value, "=")
+	if !found || len(name) == 0 || len(method) == 0 {
+		return SidecarShutdown{}, fmt.Errorf("Invalid sidecar shutdown %q, it must be NAME=http://localhost:PORT/PATH or NAME=exec:COMMAND", value)
+	}
+	if command, ok := strings.CutPrefix(method, "exec:"); ok {
+		p := shellwords.NewParser()
+		args, err := p.Parse(command)
+		if err != nil {
+			return SidecarShutdown{}, err
+		}
+		if len(args) == 0 {
+			return SidecarShutdown{}, fmt.Errorf("Command is empty in sidecar shutdown %q", value)
+		}
+		return SidecarShutdown{Container: name, Command: args}, nil
+	}
+
+	u, err := url.Parse(method)
+	if err != nil {
+		return SidecarShutdown{}, err
+	}
+	if u.Scheme != "http" {
+		return SidecarShutdown{}, fmt.Errorf("Unsupported scheme %q in sidecar shutdown %q", u.Scheme, value)
+	}
+	if host := u.Hostname(); host != "localhost" && host != "127.0.0.1" {
+		return SidecarShutdown{}, fmt.Errorf("Sidecar shutdown endpoint must be on localhost: %q", value)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return SidecarShutdown{}, fmt.Errorf("Invalid port in sidecar shutdown %q", value)
+	}
+	path := u.Path
+	if len(path) == 0 {
+		path = "/"
+	}
+	return SidecarShutdown{Container: name, Host: u.Hostname(), Port: port, Path: path}, nil
+}
+
+// shutdownSidecars signals the sidecar containers in the pods to exit.
+// Pods which are already signaled are skipped, and they are recorded in signaled.
+func (j *Job) shutdownSidecars(ctx context.Context, pods []corev1.Pod, signaled map[string]bool) error {
+	var lastErr error
+	for _, pod := range pods {
+		if signaled[pod.Name] {
+			continue
+		}
+		signaled[pod.Name] = true
+		for _, sidecar := range j.SidecarShutdowns {
+			if isNativeSidecar(pod, sidecar.Container) {
+				j.logger().Debug("Native sidecar is stopped by kubelet", "container", sidecar.Container)
+				continue
+			}
+			if !containerIsRunning(pod, sidecar.Container) {
+				continue
+			}
+			j.logger().Info("Stopping sidecar container", "container", sidecar.Container, "pod", pod.Name)
+			if err := j.shutdownSidecar(ctx, pod, sidecar); err != nil {
+				j.logger().Warn("Failed to stop sidecar container", "container", sidecar.Container, "pod", pod.Name, "error", err)
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+func (j *Job) shutdownSidecar(ctx context.Context, pod corev1.Pod, sidecar SidecarShutdown) error {
+	return j.execInContainer(ctx, pod, sidecar.Container, sidecar.command())
+}
+
+// command returns the command which stops the sidecar. The HTTP endpoint is requested in the sidecar container,
+// because it usually listens only on the loopback address of the pod.
+func (s SidecarShutdown) command() []string {
+	if len(s.Command) > 0 {
+		return s.Command
+	}
+	host := s.Host
+	if len(host) == 0 {
+		host = "localhost"
+	}
+	url := fmt.Sprintf("http://%s:%d%s", host, s.Port, s.Path)
+	return []string{"sh", "-c", fmt.Sprintf("curl -fsS -X POST %[1]s || wget -q -O - --post-data= %[1]s", url)}
+}
+
+// execInContainer executes the command in the container, and logs the output.
+func (j *Job) execInContainer(ctx context.Context, pod corev1.Pod, container string, command []string) error {
+	var stdout bytes.Buffer
+	err := j.execStream(ctx, pod, container, command, &stdout)
+	j.logger().Debug("Executed command", "command", command, "container", container, "stdout", stdout.String())
+	return err
+}
+
+// execStream executes the command in the container, and writes the stdout to the writer.
+// The stderr is included in the error when the command fails.
+func (j *Job) execStream(ctx context.Context, pod corev1.Pod, container string, command []string, stdout io.Writer) error {
+	if j.restConfig == nil {
+		return errors.New("Rest config is required to execute a command in the container")
+	}
+	request := j.client.CoreV1().RESTClient().Post().
+		Namespace(pod.Namespace).
+		Resource("pods").
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(j.restConfig, "POST", request.URL())
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: stdout,
+		Stderr: &stderr,
+	})
+	if err != nil && stderr.Len() > 0 {
+		return errors.Wrap(err, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// isNativeSidecar checks whether the container is an init container which has restartPolicy Always.
+// Kubelet stops native sidecars after all regular containers are terminated.
+func isNativeSidecar(pod corev1.Pod, containerName string) bool {
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == containerName {
+			return container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+		}
+	}
+	return false
+}
+
+func containerIsRunning(pod corev1.Pod, containerName string) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == containerName {
+			return status.State.Running != nil
+		}
+	}
+	return false
+}
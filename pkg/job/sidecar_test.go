@@ -0,0 +1,75 @@
+package job
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	v1core "k8s.io/api/core/v1"
+)
+
+func TestParseSidecarShutdown(t *testing.T) {
+	sidecar, err := ParseSidecarShutdown("istio-proxy=http://localhost:15020/quitquitquit")
+	if err != nil {
+		t.Error(err)
+	}
+	if sidecar.Container != "istio-proxy" || sidecar.Port != 15020 || sidecar.Path != "/quitquitquit" {
+		t.Errorf("sidecar shutdown does not match: %v", sidecar)
+	}
+	if command := sidecar.command(); len(command) != 3 || !strings.Contains(command[2], "curl -fsS -X POST http://localhost:15020/quitquitquit") {
+		t.Errorf("endpoint should be requested in the sidecar container: %v", command)
+	}
+
+	sidecar, err = ParseSidecarShutdown("cloud-sql-proxy=exec:/bin/kill -TERM 1")
+	if err != nil {
+		t.Error(err)
+	}
+	if sidecar.Container != "cloud-sql-proxy" || !reflect.DeepEqual(sidecar.Command, []string{"/bin/kill", "-TERM", "1"}) {
+		t.Errorf("sidecar shutdown does not match: %v", sidecar)
+	}
+
+	invalids := []string{
+		"istio-proxy",
+		"=exec:/bin/kill 1",
+		"istio-proxy=exec:",
+		"istio-proxy=https://localhost:15020/quitquitquit",
+		"istio-proxy=http://example.com:15020/quitquitquit",
+		"istio-proxy=http://localhost/quitquitquit",
+	}
+	for _, invalid := range invalids {
+		if _, err := ParseSidecarShutdown(invalid); err == nil {
+			t.Errorf("%s should be invalid", invalid)
+		}
+	}
+}
+
+func TestIsNativeSidecar(t *testing.T) {
+	always := v1core.ContainerRestartPolicyAlways
+	pod := v1core.Pod{
+		Spec: v1core.PodSpec{
+			InitContainers: []v1core.Container{
+				{
+					Name:          "istio-proxy",
+					RestartPolicy: &always,
+				},
+				{
+					Name: "init",
+				},
+			},
+			Containers: []v1core.Container{
+				{
+					Name: "alpine",
+				},
+			},
+		},
+	}
+	if !isNativeSidecar(pod, "istio-proxy") {
+		t.Error("istio-proxy should be a native sidecar")
+	}
+	if isNativeSidecar(pod, "init") {
+		t.Error("init should not be a native sidecar")
+	}
+	if isNativeSidecar(pod, "alpine") {
+		t.Error("alpine should not be a native sidecar")
+	}
+}
@@ -3,13 +3,13 @@ package job
 import (
 	"context"
 	"io"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,13 +23,20 @@ type Watcher struct {
 
 	// Target container name.
 	Container string
+	// Writer which the logs are written to. If you set nil, os.Stdout is used.
+	Output io.Writer
+	// Logger to write diagnostic messages. If you set nil, nothing is written.
+	Logger *slog.Logger
+	// Handler which receives pod and container events of the job.
+	// Job.Run does not set it, because WaitJobComplete emits the same events.
+	Events EventHandler
 }
 
 // NewWatcher returns a new Watcher struct.
 func NewWatcher(client kubernetes.Interface, container string) *Watcher {
 	return &Watcher{
-		client,
-		container,
+		client:    client,
+		Container: container,
 	}
 }
 
@@ -38,15 +45,17 @@ func NewWatcher(client kubernetes.Interface, container string) *Watcher {
 // When restartPolicy is Never, the Job create a new Pod if the specified command is failed.
 // So we must trace all Pods even though the Pod is failed.
 // And it isn't necessary to stop the loop because the Job is watched in WaitJobComplete.
-func (w *Watcher) Watch(job *v1.Job, ctx context.Context) error {
+func (w *Watcher) Watch(ctx context.Context, job *v1.Job) error {
 	currentPodList := []corev1.Pod{}
 	errCh := make(chan error, 1)
+	tracker := newPodTracker(w.Events, job)
 retry:
 	for {
 		newPodList, err := w.FindPods(ctx, job)
 		if err != nil {
 			return err
 		}
+		tracker.observe(newPodList)
 
 		incrementalPodList := diffPods(currentPodList, newPodList)
 
@@ -59,6 +68,8 @@ retry:
 		select {
 		case err := <-errCh:
 			return err
+		case <-ctx.Done():
+			return nil
 		case <-time.After(1 * time.Second):
 			currentPodList = newPodList
 			continue retry
@@ -90,13 +101,13 @@ func (w *Watcher) WatchPods(ctx context.Context, pods []corev1.Pod) error {
 				Param("follow", strconv.FormatBool(true)).
 				Param("container", w.Container).
 				Param("timestamps", strconv.FormatBool(false))
-			err = readStreamLog(ctx, request, startedPod)
+			err = readStreamLog(ctx, request, startedPod, w.output())
 			errCh <- err
 		}(pod)
 	}
 
 	if err := <-errCh; err != nil {
-		log.Error(err)
+		w.logger().Error("Failed to watch pods", "error", err)
 		return err
 	}
 	wg.Wait()
@@ -149,14 +160,21 @@ func parseLabels(labels map[string]string) string {
 	return strings.Join(query, ",")
 }
 
-// readStreamLog reads rest request, and output the log to stdout with stream.
-func readStreamLog(ctx context.Context, request *restclient.Request, pod corev1.Pod) error {
+func (w *Watcher) output() io.Writer {
+	if w.Output == nil {
+		return os.Stdout
+	}
+	return w.Output
+}
+
+// readStreamLog reads rest request, and output the log to the writer with stream.
+func readStreamLog(ctx context.Context, request *restclient.Request, pod corev1.Pod, output io.Writer) error {
 	readCloser, err := request.Stream(ctx)
 	if err != nil {
 		return err
 	}
 	defer readCloser.Close()
-	_, err = io.Copy(os.Stdout, readCloser)
+	_, err = io.Copy(output, readCloser)
 	return err
 }
 
@@ -0,0 +1,308 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+const (
+	// WhenOnSuccess runs the step when all dependencies are succeeded.
+	WhenOnSuccess = "on-success"
+	// WhenOnFailure runs the step when any dependency is failed.
+	WhenOnFailure = "on-failure"
+	// WhenAlways runs the step after all dependencies are finished, whether they are succeeded or not.
+	WhenAlways = "always"
+)
+
+// Workflow is a set of steps which are executed as a DAG.
+type Workflow struct {
+	Steps []WorkflowStep `json:"steps"`
+	// Logger to write diagnostic messages. If you set nil, nothing is written.
+	Logger *slog.Logger `json:"-"`
+	// Handler which receives lifecycle events of the jobs in the steps.
+	Events EventHandler `json:"-"`
+	// Policy which every step must satisfy. If you set nil, the steps are not checked.
+	Policy *Policy `json:"-"`
+	// OverridePolicy runs the steps even if they violate the policy.
+	OverridePolicy bool `json:"-"`
+}
+
+// WorkflowStep is a job in the workflow.
+// Overrides are the same as the flags of run command.
+type WorkflowStep struct {
+	// Unique name of the step.
+	Name string `json:"name"`
+	// Job template file or URL.
+	Template      string `json:"template"`
+	JobName       string `json:"jobName,omitempty"`
+	Args          string `json:"args,omitempty"`
+	Image         string `json:"image,omitempty"`
+	Resources     string `json:"resources,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+	Container     string `json:"container,omitempty"`
+	IgnoreSidecar bool   `json:"ignoreSidecar,omitempty"`
+	// Timeout seconds. If you set 0, timeout is ignored.
+	Timeout int `json:"timeout,omitempty"`
+	// Names of the steps which must be finished before this step.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// on-success, on-failure or always. Default is on-success.
+	When string `json:"when,omitempty"`
+	// all, succeeded or failed. Default is all.
+	Cleanup string `json:"cleanup,omitempty"`
+}
+
+// StepStatus is a status of the step.
+type StepStatus string
+
+const (
+	// StepSucceeded means the job of the step is succeeded.
+	StepSucceeded StepStatus = "Succeeded"
+	// StepFailed means the job of the step is failed.
+	StepFailed StepStatus = "Failed"
+	// StepSkipped means the step is not run because of the condition.
+	StepSkipped StepStatus = "Skipped"
+)
+
+// StepResult is a result of the step.
+type StepResult struct {
+	Name     string
+	JobName  string
+	Status   StepStatus
+	Err      error
+	Duration time.Duration
+}
+
+// LoadWorkflow reads a workflow file, and validates it.
+func LoadWorkflow(file string) (*Workflow, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var workflow Workflow
+	if err := yaml.Unmarshal(data, &workflow); err != nil {
+		return nil, err
+	}
+	if err := workflow.Validate(); err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// Validate checks names, dependencies, conditions and cleanup policies of the steps.
+// It also checks that the dependencies do not have a cycle.
+func (w *Workflow) Validate() error {
+	if len(w.Steps) == 0 {
+		return errors.New("Workflow does not have any steps")
+	}
+	steps := map[string]WorkflowStep{}
+	for _, step := range w.Steps {
+		if len(step.Name) == 0 {
+			return errors.New("Step name is required")
+		}
+		if _, ok := steps[step.Name]; ok {
+			return fmt.Errorf("Step %s is duplicated", step.Name)
+		}
+		if len(step.Template) == 0 {
+			return fmt.Errorf("Template is required in step %s", step.Name)
+		}
+		switch step.When {
+		case "", WhenOnSuccess, WhenOnFailure, WhenAlways:
+		default:
+			return fmt.Errorf("Step %s has invalid when %q, please set 'on-success', 'on-failure' or 'always'", step.Name, step.When)
+		}
+		switch step.Cleanup {
+		case "", All.String(), Succeeded.String(), Failed.String():
+		default:
+			return fmt.Errorf("Step %s has invalid cleanup %q, please set 'all', 'succeeded' or 'failed'", step.Name, step.Cleanup)
+		}
+		steps[step.Name] = step
+	}
+	for _, step := range w.Steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := steps[dep]; !ok {
+				return fmt.Errorf("Step %s depends on unknown step %s", step.Name, dep)
+			}
+		}
+	}
+
+	// Detect a cycle with depth first search.
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := map[string]int{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch states[name] {
+		case visiting:
+			return fmt.Errorf("Workflow has a cycle at step %s", name)
+		case visited:
+			return nil
+		}
+		states[name] = visiting
+		for _, dep := range steps[name].DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		states[name] = visited
+		return nil
+	}
+	for _, step := range w.Steps {
+		if err := visit(step.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run executes the steps as a DAG. Steps which do not depend on each other run at the same time.
+// Logs of each step are prefixed with the step name.
+// The results are returned in the same order as the steps.
+func (w *Workflow) Run(ctx context.Context, kubeconfig KubeconfigOptions, followLogs bool) []StepResult {
+	var mu sync.Mutex
+	return w.run(func(step WorkflowStep) (string, error) {
+		opts, err := w.stepOptions(step)
+		if err != nil {
+			return "", err
+		}
+		j, err := New(ctx, append([]Option{WithKubeconfigOptions(kubeconfig)}, opts...)...)
+		if err != nil {
+			return "", err
+		}
+		writer := newPrefixWriter(os.Stdout, &mu, "["+step.Name+"] ")
+		defer writer.Flush()
+		j.Output = writer
+		cleanup := step.Cleanup
+		if len(cleanup) == 0 {
+			cleanup = All.String()
+		}
+		return j.CurrentJob.Name, j.RunAndCleanup(ctx, cleanup, step.IgnoreSidecar, followLogs)
+	})
+}
+
+// Namespaces returns the namespace where the job of each step runs, in the same order as the steps.
+// The templates are read to resolve the namespaces, but no job is created.
+func (w *Workflow) Namespaces(ctx context.Context, kubeconfig KubeconfigOptions) ([]string, error) {
+	namespaces := make([]string, 0, len(w.Steps))
+	defaultNamespace := ""
+	for _, step := range w.Steps {
+		namespace := step.Namespace
+		if len(namespace) == 0 {
+			template, err := readTemplate(ctx, step.Template)
+			if err != nil {
+				return nil, err
+			}
+			namespace = template.Namespace
+		}
+		if len(namespace) == 0 {
+			if len(defaultNamespace) == 0 {
+				_, ns, err := newRestConfig(kubeconfig)
+				if err != nil {
+					return nil, err
+				}
+				defaultNamespace = ns
+			}
+			namespace = defaultNamespace
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, nil
+}
+
+// stepOptions returns the options to build the job of the step.
+func (w *Workflow) stepOptions(step WorkflowStep) ([]Option, error) {
+	resources, err := ParseResources(step.Resources)
+	if err != nil {
+		return nil, err
+	}
+	return []Option{
+		WithTemplate(step.Template),
+		WithName(step.JobName),
+		WithArgsString(step.Args),
+		WithImage(step.Image),
+		WithResources(resources),
+		WithNamespace(step.Namespace),
+		WithContainer(step.Container),
+		WithTimeout(time.Duration(step.Timeout) * time.Second),
+		WithLogger(w.Logger),
+		WithEventHandler(w.Events),
+		WithPolicy(w.Policy, w.OverridePolicy),
+	}, nil
+}
+
+func (w *Workflow) run(runStep func(WorkflowStep) (string, error)) []StepResult {
+	results := make([]StepResult, len(w.Steps))
+	indexes := map[string]int{}
+	done := map[string]chan struct{}{}
+	for i, step := range w.Steps {
+		indexes[step.Name] = i
+		done[step.Name] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for i, step := range w.Steps {
+		wg.Add(1)
+		go func(i int, step WorkflowStep) {
+			defer wg.Done()
+			defer close(done[step.Name])
+
+			statuses := []StepStatus{}
+			for _, dep := range step.DependsOn {
+				<-done[dep]
+				statuses = append(statuses, results[indexes[dep]].Status)
+			}
+			if !shouldRunStep(step.When, statuses) {
+				loggerOrDiscard(w.Logger).Info("Skipping step", "step", step.Name)
+				results[i] = StepResult{Name: step.Name, Status: StepSkipped}
+				return
+			}
+
+			loggerOrDiscard(w.Logger).Info("Starting step", "step", step.Name)
+			started := time.Now()
+			jobName, err := runStep(step)
+			status := StepSucceeded
+			if err != nil {
+				status = StepFailed
+			}
+			results[i] = StepResult{
+				Name:     step.Name,
+				JobName:  jobName,
+				Status:   status,
+				Err:      err,
+				Duration: time.Since(started),
+			}
+		}(i, step)
+	}
+	wg.Wait()
+	return results
+}
+
+// shouldRunStep decides whether the step runs from the condition and the statuses of the dependencies.
+func shouldRunStep(when string, statuses []StepStatus) bool {
+	switch when {
+	case WhenAlways:
+		return true
+	case WhenOnFailure:
+		for _, status := range statuses {
+			if status == StepFailed {
+				return true
+			}
+		}
+		return false
+	default:
+		for _, status := range statuses {
+			if status != StepSucceeded {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,149 @@
+package job
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestLoadWorkflow(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "workflow.yaml")
+	content := `steps:
+  - name: migrate
+    template: ./job.yaml
+    args: "rake db:migrate"
+  - name: seed
+    template: ./job.yaml
+    dependsOn: [migrate]
+    cleanup: succeeded
+`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	workflow, err := LoadWorkflow(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workflow.Steps) != 2 || workflow.Steps[0].Args != "rake db:migrate" || workflow.Steps[1].DependsOn[0] != "migrate" {
+		t.Errorf("workflow does not match: %v", workflow)
+	}
+}
+
+func TestValidateWorkflow(t *testing.T) {
+	invalids := map[string]Workflow{
+		"empty": {},
+		"duplicated": {Steps: []WorkflowStep{
+			{Name: "a", Template: "job.yaml"},
+			{Name: "a", Template: "job.yaml"},
+		}},
+		"unknown dependency": {Steps: []WorkflowStep{
+			{Name: "a", Template: "job.yaml", DependsOn: []string{"b"}},
+		}},
+		"invalid when": {Steps: []WorkflowStep{
+			{Name: "a", Template: "job.yaml", When: "never"},
+		}},
+		"invalid cleanup": {Steps: []WorkflowStep{
+			{Name: "a", Template: "job.yaml", Cleanup: "none"},
+		}},
+		"cycle": {Steps: []WorkflowStep{
+			{Name: "a", Template: "job.yaml", DependsOn: []string{"c"}},
+			{Name: "b", Template: "job.yaml", DependsOn: []string{"a"}},
+			{Name: "c", Template: "job.yaml", DependsOn: []string{"b"}},
+		}},
+	}
+	for name, workflow := range invalids {
+		if err := workflow.Validate(); err == nil {
+			t.Errorf("%s workflow should be invalid", name)
+		}
+	}
+}
+
+func TestShouldRunStep(t *testing.T) {
+	succeeded := []StepStatus{StepSucceeded, StepSucceeded}
+	failed := []StepStatus{StepSucceeded, StepFailed}
+	skipped := []StepStatus{StepSkipped}
+	if !shouldRunStep("", succeeded) || shouldRunStep("", failed) || shouldRunStep("", skipped) {
+		t.Error("on-success step should run only when all dependencies are succeeded")
+	}
+	if shouldRunStep(WhenOnFailure, succeeded) || !shouldRunStep(WhenOnFailure, failed) || shouldRunStep(WhenOnFailure, skipped) {
+		t.Error("on-failure step should run only when any dependency is failed")
+	}
+	if !shouldRunStep(WhenAlways, succeeded) || !shouldRunStep(WhenAlways, failed) || !shouldRunStep(WhenAlways, skipped) {
+		t.Error("always step should run")
+	}
+}
+
+func TestRunWorkflow(t *testing.T) {
+	workflow := Workflow{Steps: []WorkflowStep{
+		{Name: "migrate", Template: "job.yaml"},
+		{Name: "seed", Template: "job.yaml", DependsOn: []string{"migrate"}},
+		{Name: "smoke", Template: "job.yaml", DependsOn: []string{"seed"}},
+		{Name: "rollback", Template: "job.yaml", DependsOn: []string{"seed"}, When: WhenOnFailure},
+		{Name: "notify", Template: "job.yaml", DependsOn: []string{"smoke", "rollback"}, When: WhenAlways},
+	}}
+	var mu sync.Mutex
+	order := []string{}
+	results := workflow.run(func(step WorkflowStep) (string, error) {
+		mu.Lock()
+		order = append(order, step.Name)
+		mu.Unlock()
+		if step.Name == "seed" {
+			return step.Name + "-job", errors.New("seed is failed")
+		}
+		return step.Name + "-job", nil
+	})
+
+	expected := map[string]StepStatus{
+		"migrate":  StepSucceeded,
+		"seed":     StepFailed,
+		"smoke":    StepSkipped,
+		"rollback": StepSucceeded,
+		"notify":   StepSucceeded,
+	}
+	for _, result := range results {
+		if result.Status != expected[result.Name] {
+			t.Errorf("%s should be %s, but %s", result.Name, expected[result.Name], result.Status)
+		}
+	}
+	if len(order) != 4 || order[0] != "migrate" || order[1] != "seed" || order[3] != "notify" {
+		t.Errorf("steps are not run in order: %v", order)
+	}
+}
+
+func TestWorkflowStepPolicy(t *testing.T) {
+	workflow := Workflow{
+		Steps:  []WorkflowStep{{Name: "migrate", Template: "../../example/job.yaml", Container: "alpine"}},
+		Policy: &Policy{AllowedNamespaces: []string{"batch"}},
+	}
+	opts, err := workflow.stepOptions(workflow.Steps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := mockedKubernetes{mockedBatch: mockedBatchV1{mockedJob: mockedJob{}}}
+	j, err := New(context.Background(), append(opts, WithClient(client))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.RunJob(context.Background()); err == nil || !strings.Contains(err.Error(), "namespace default is not allowed") {
+		t.Errorf("step should violate the policy: %v", err)
+	}
+}
+
+func TestWorkflowNamespaces(t *testing.T) {
+	workflow := Workflow{Steps: []WorkflowStep{
+		{Name: "migrate", Template: "../../example/job.yaml"},
+		{Name: "seed", Template: "../../example/job.yaml", Namespace: "prod-db"},
+	}}
+	namespaces, err := workflow.Namespaces(context.Background(), KubeconfigOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(namespaces) != 2 || namespaces[0] != "default" || namespaces[1] != "prod-db" {
+		t.Errorf("namespaces are not resolved: %v", namespaces)
+	}
+}